@@ -7,6 +7,7 @@ import (
 	"encoding/base64"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/netlify/netlify-git-api/lockout"
 	"github.com/netlify/netlify-git-api/repo"
 	"github.com/rs/cors"
 	"golang.org/x/net/context"
@@ -19,14 +20,41 @@ const (
 // API is the REST API around git repos
 type API struct {
 	resolver Resolver
+	config   *Config
+}
+
+// Config holds the options for the API
+type Config struct {
+	// ReadOnly refuses all requests that would write to the repository
+	ReadOnly bool
+	// AllowedOrigins are the origins (ie. https://cms.example.com) that can
+	// open the auth popup and receive tokens from it
+	AllowedOrigins []string
+	// Lockout tracks failed logins to slow down and lock out password guessing
+	Lockout *lockout.Tracker
+	// ClientIPHeader is the header (ie. X-Forwarded-For) a trusted proxy in
+	// front of the API sets to the IP of the client. Only set it behind a
+	// proxy that overwrites or appends to the header, clients can send any
+	// value otherwise.
+	ClientIPHeader string
 }
 
 // Resolver handlers user and repo lookups for requests
 type Resolver interface {
-	Authenticate(string, string) (string, error)
+	Authenticate(email, password, otp string) (*Token, error)
+	Refresh(string) (*Token, error)
+	Revoke(string) error
 	GetRepo(*http.Request) (*repo.Repo, error)
 }
 
+// Token is the JSON object returned when granting a token
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
 func (a *API) wrap(fn func(http.ResponseWriter, *http.Request, httprouter.Params, context.Context)) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		repo, err := a.resolver.GetRepo(r)
@@ -46,62 +74,137 @@ func (a *API) wrap(fn func(http.ResponseWriter, *http.Request, httprouter.Params
 	}
 }
 
-func (a *API) tokenFn() httprouter.Handle {
-	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-		if r.FormValue("grant_type") != "client_credentials" {
-			NotAuthorizedError(w, "Unsupported grant type")
+// wrapWrite is like wrap for handlers that write to the repository and
+// refuses the request for read only servers and users
+func (a *API) wrapWrite(fn func(http.ResponseWriter, *http.Request, httprouter.Params, context.Context)) httprouter.Handle {
+	return a.wrap(func(w http.ResponseWriter, r *http.Request, p httprouter.Params, ctx context.Context) {
+		if a.config.ReadOnly {
+			ForbiddenError(w, "This server is read only")
+			return
+		}
+		if getRepo(ctx).ReadOnly() {
+			ForbiddenError(w, "You only have read access to this repository")
 			return
 		}
 
-		email, pw, ok := r.BasicAuth()
-		if !ok {
-			NotAuthorizedError(w, "Missing email or password")
+		fn(w, r, p, ctx)
+	})
+}
+
+func (a *API) tokenFn() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		var token *Token
+		var err error
+		switch r.FormValue("grant_type") {
+		case "client_credentials":
+			email, pw, ok := r.BasicAuth()
+			if !ok {
+				NotAuthorizedError(w, "Missing email or password")
+				return
+			}
+			wait, lockErr := a.loginWait(r, email)
+			if lockErr != nil {
+				HandleError(w, lockErr)
+				return
+			}
+			if wait > 0 {
+				TooManyRequestsError(w, "Too many failed login attempts", wait)
+				return
+			}
+			token, err = a.resolver.Authenticate(email, pw, r.FormValue("otp"))
+			if err == nil {
+				err = a.recordLogin(r, email, token != nil)
+			}
+		case "refresh_token":
+			refreshToken := r.FormValue("refresh_token")
+			if refreshToken == "" {
+				BadRequestError(w, "Missing refresh token")
+				return
+			}
+			token, err = a.resolver.Refresh(refreshToken)
+		default:
+			BadRequestError(w, "Unsupported grant type")
 			return
 		}
 
-		token, err := a.resolver.Authenticate(email, pw)
 		if err != nil {
 			HandleError(w, err)
 			return
 		}
-		if token == "" {
+		if token == nil {
 			NotAuthorizedError(w, "Access Denied")
 			return
 		}
 
-		resp := map[string]string{
-			"access_token": string(token),
-			"token_type":   "bearer",
+		sendJSON(w, 200, token)
+	}
+}
+
+// revokeFn revokes the bearer token of the request and the `refresh_token`
+// parameter if set
+func (a *API) revokeFn() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		accessToken, ok := BearerToken(r)
+		if !ok {
+			NotAuthorizedError(w, "Missing access token")
+			return
+		}
+
+		if err := a.resolver.Revoke(accessToken); err != nil {
+			HandleError(w, err)
+			return
+		}
+		if refreshToken := r.FormValue("refresh_token"); refreshToken != "" {
+			if err := a.resolver.Revoke(refreshToken); err != nil {
+				HandleError(w, err)
+				return
+			}
 		}
-		sendJSON(w, 200, resp)
+
+		w.WriteHeader(204)
 	}
 }
 
-// NewAPI instantiates a new API with a resolver. Sync determines whether to
-// sync the underlying repo with a remote origin
-func NewAPI(resolver Resolver) http.Handler {
-	api := API{resolver: resolver}
+// NewAPI instantiates a new API with a resolver and a config
+func NewAPI(resolver Resolver, config *Config) http.Handler {
+	if config == nil {
+		config = &Config{}
+	}
+	api := API{resolver: resolver, config: config}
 	router := httprouter.New()
 	router.GET("/", Index)
 	router.POST("/token", api.tokenFn())
+	router.DELETE("/token", api.revokeFn())
+	router.GET("/auth", api.Auth)
+	router.POST("/auth", api.Auth)
 	router.GET("/files/*path", api.wrap(GetFile))
-	router.DELETE("/files/*path", api.wrap(DeleteFile))
+	router.PUT("/files/*path", api.wrapWrite(UpdateFile))
+	router.DELETE("/files/*path", api.wrapWrite(DeleteFile))
 
-	router.POST("/blobs", api.wrap(CreateBlob))
+	router.POST("/blobs", api.wrapWrite(CreateBlob))
 	router.GET("/blobs/:sha", api.wrap(GetBlob))
 
-	router.POST("/trees", api.wrap(CreateTree))
+	router.POST("/trees", api.wrapWrite(CreateTree))
 	router.GET("/trees/:sha", api.wrap(GetTree))
 
-	router.POST("/commits", api.wrap(CreateCommit))
+	router.GET("/commits", api.wrap(ListCommits))
+	router.POST("/commits", api.wrapWrite(CreateCommit))
+	router.POST("/commits/batch", api.wrapWrite(CreateBatchCommit))
 	router.GET("/commits/:sha", api.wrap(GetCommit))
 
+	router.GET("/compare/*basehead", api.wrap(Compare))
+	router.POST("/merges", api.wrapWrite(CreateMerge))
+
+	router.GET("/refs", api.wrap(ListRefs))
+	router.POST("/refs", api.wrapWrite(CreateRef))
 	router.GET("/refs/*ref", api.wrap(GetRef))
-	router.PATCH("/refs/*ref", api.wrap(UpdateRef))
+	router.PATCH("/refs/*ref", api.wrapWrite(UpdateRef))
+	router.DELETE("/refs/*ref", api.wrapWrite(DeleteRef))
 
 	corsHandler := cors.New(cors.Options{
 		AllowedMethods:   []string{"GET", "POST", "PATCH", "PUT", "DELETE"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "If-Match"},
+		ExposedHeaders:   []string{"ETag"},
 		AllowCredentials: true,
 	})
 
@@ -127,3 +230,13 @@ func parseBasicAuth(auth string) (username, password string, ok bool) {
 	}
 	return cs[:s], cs[s+1:], true
 }
+
+// BearerToken returns the token from a "Bearer <token>" Authorization header
+func BearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, prefix) || len(auth) == len(prefix) {
+		return "", false
+	}
+	return auth[len(prefix):], true
+}
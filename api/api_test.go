@@ -0,0 +1,302 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/netlify/netlify-git-api/repo"
+	"golang.org/x/net/context"
+	"gopkg.in/libgit2/git2go.v22"
+)
+
+type testUser struct {
+	readOnly bool
+}
+
+func (u *testUser) Name() string {
+	return "Test User"
+}
+
+func (u *testUser) Email() string {
+	return "test@example.com"
+}
+
+func (u *testUser) HasPermission(action, pathname string) bool {
+	return true
+}
+
+func (u *testUser) HasRole(role string) bool {
+	return false
+}
+
+func (u *testUser) ReadOnly() bool {
+	return u.readOnly
+}
+
+// testResolver resolves every request to the same repo and authenticates
+// test@example.com with password (and otp if set)
+type testResolver struct {
+	repo     *repo.Repo
+	password string
+	otp      string
+}
+
+func (r *testResolver) Authenticate(email, password, otp string) (*Token, error) {
+	if email != "test@example.com" || password == "" || password != r.password {
+		return nil, nil
+	}
+	if r.otp != "" && otp != r.otp {
+		return nil, ErrOTPRequired
+	}
+	return &Token{AccessToken: "access-token", TokenType: "bearer", RefreshToken: "refresh-token"}, nil
+}
+
+func (r *testResolver) Refresh(refreshToken string) (*Token, error) {
+	return nil, nil
+}
+
+func (r *testResolver) Revoke(token string) error {
+	return nil
+}
+
+func (r *testResolver) GetRepo(req *http.Request) (*repo.Repo, error) {
+	return r.repo, nil
+}
+
+// testRepo is a bare repository in a temp dir for handler tests
+type testRepo struct {
+	*repo.Repo
+	git *git.Repository
+	dir string
+}
+
+// newTestRepo creates a repository where master has a single commit with
+// files (paths mapped to contents)
+func newTestRepo(t *testing.T, files map[string]string) *testRepo {
+	dir, err := ioutil.TempDir("", "netlify-git-api-test")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+
+	gitRepo, err := git.InitRepository(dir, true)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Error creating repository: %v", err)
+	}
+
+	currentRepo, err := repo.Open(&testUser{}, dir, false)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Error opening repository: %v", err)
+	}
+
+	tr := &testRepo{Repo: currentRepo, git: gitRepo, dir: dir}
+	tr.setRef(t, "refs/heads/master", tr.commit(t, "", files).Sha)
+	return tr
+}
+
+// Close removes the repository
+func (tr *testRepo) Close() {
+	os.RemoveAll(tr.dir)
+}
+
+// commit commits files on top of parent (empty for a root commit) without
+// moving any ref. An empty content removes the file.
+func (tr *testRepo) commit(t *testing.T, parent string, files map[string]string) *repo.Commit {
+	var treeSha string
+	parents := []string{}
+	if parent != "" {
+		commit, err := tr.GetCommit(parent)
+		if err != nil {
+			t.Fatalf("Error looking up parent %v: %v", parent, err)
+		}
+		treeSha = commit.Tree.Sha
+		parents = append(parents, parent)
+	}
+
+	for pathname, content := range files {
+		var sha string
+		if content != "" {
+			blob, err := tr.PutBlob(strings.NewReader(content))
+			if err != nil {
+				t.Fatalf("Error writing blob for %v: %v", pathname, err)
+			}
+			sha = blob.Sha
+		}
+		tree, err := tr.UpdateTreePath(treeSha, pathname, sha, "")
+		if err != nil {
+			t.Fatalf("Error updating %v: %v", pathname, err)
+		}
+		treeSha = tree.Sha
+	}
+
+	if treeSha == "" {
+		tree, err := tr.CreateTree("", nil)
+		if err != nil {
+			t.Fatalf("Error creating empty tree: %v", err)
+		}
+		treeSha = tree.Sha
+	}
+
+	commit, err := tr.CreateCommit(treeSha, "Test commit", parents)
+	if err != nil {
+		t.Fatalf("Error creating commit: %v", err)
+	}
+	return commit
+}
+
+// setRef points a ref to a sha, bypassing all permission checks
+func (tr *testRepo) setRef(t *testing.T, name, sha string) {
+	oid, err := git.NewOid(sha)
+	if err != nil {
+		t.Fatalf("Error parsing %v: %v", sha, err)
+	}
+	sig := &git.Signature{Name: "Test User", Email: "test@example.com", When: time.Now()}
+	if _, err := tr.git.CreateReference(name, oid, true, sig, ""); err != nil {
+		t.Fatalf("Error setting %v: %v", name, err)
+	}
+}
+
+// refSha returns the sha a ref points to
+func (tr *testRepo) refSha(t *testing.T, name string) string {
+	ref, err := tr.GetRef(name)
+	if err != nil {
+		t.Fatalf("Error looking up %v: %v", name, err)
+	}
+	return ref.Object.Sha
+}
+
+// fileSha returns the blob sha of a file on master or an empty string
+func (tr *testRepo) fileSha(pathname string) string {
+	file, err := tr.GetFileAt("master", pathname)
+	if err != nil {
+		return ""
+	}
+	return file.Sha
+}
+
+// fileContent reads a file on master
+func (tr *testRepo) fileContent(t *testing.T, pathname string) string {
+	file, err := tr.GetFileAt("master", pathname)
+	if err != nil {
+		t.Fatalf("Error reading %v: %v", pathname, err)
+	}
+	blob, err := tr.GetBlob(file.Sha)
+	if err != nil {
+		t.Fatalf("Error reading blob %v: %v", file.Sha, err)
+	}
+	data, err := ioutil.ReadAll(blob)
+	if err != nil {
+		t.Fatalf("Error reading blob %v: %v", file.Sha, err)
+	}
+	return string(data)
+}
+
+// expand replaces {{path}} placeholders with the sha of the file at path on
+// master (empty if it doesn't exist)
+func (tr *testRepo) expand(s string) string {
+	for {
+		start := strings.Index(s, "{{")
+		if start < 0 {
+			return s
+		}
+		end := strings.Index(s[start:], "}}")
+		if end < 0 {
+			return s
+		}
+		s = s[:start] + tr.fileSha(s[start+2:start+end]) + s[start+end+2:]
+	}
+}
+
+// serve calls a handler with the repo in the context
+func (tr *testRepo) serve(fn func(http.ResponseWriter, *http.Request, httprouter.Params, context.Context), method, url, body string, header http.Header, params httprouter.Params) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	w := httptest.NewRecorder()
+	fn(w, req, params, context.WithValue(context.Background(), "repo", tr.Repo))
+	return w
+}
+
+// pathParams returns the router params for a /files/*path route
+func pathParams(pathname string) httprouter.Params {
+	return httprouter.Params{{Key: "path", Value: "/" + pathname}}
+}
+
+// decodeError reads the error from a response
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) *Error {
+	e := &Error{}
+	if err := json.NewDecoder(w.Body).Decode(e); err != nil {
+		t.Fatalf("Error decoding response %q: %v", w.Body.String(), err)
+	}
+	return e
+}
+
+func TestReadOnly(t *testing.T) {
+	tr := newTestRepo(t, map[string]string{"README.md": "readme"})
+	defer tr.Close()
+
+	readOnlyRepo, err := repo.Open(&testUser{readOnly: true}, tr.dir, false)
+	if err != nil {
+		t.Fatalf("Error opening repository: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		repo     *repo.Repo
+		readOnly bool
+		method   string
+		status   int
+	}{
+		{name: "read", repo: tr.Repo, method: "GET", status: 200},
+		{name: "write", repo: tr.Repo, method: "PUT", status: 200},
+		{name: "read only server read", repo: tr.Repo, readOnly: true, method: "GET", status: 200},
+		{name: "read only server write", repo: tr.Repo, readOnly: true, method: "PUT", status: 403},
+		{name: "read only user read", repo: readOnlyRepo, method: "GET", status: 200},
+		{name: "read only user write", repo: readOnlyRepo, method: "PUT", status: 403},
+	}
+
+	for _, test := range tests {
+		handler := NewAPI(&testResolver{repo: test.repo}, &Config{ReadOnly: test.readOnly})
+		body := tr.expand(`{"content": "x", "branch": "master", "sha": "{{README.md}}"}`)
+		req, _ := http.NewRequest(test.method, "/files/README.md", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != test.status {
+			t.Errorf("%v: expected status %v, got %v: %v", test.name, test.status, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		header string
+		token  string
+		ok     bool
+	}{
+		{header: "Bearer abc", token: "abc", ok: true},
+		{header: "Bearer ngk_123", token: "ngk_123", ok: true},
+		{header: "Bearer ", ok: false},
+		{header: "bearer abc", ok: false},
+		{header: "Basic abc", ok: false},
+		{header: "", ok: false},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", test.header)
+		token, ok := BearerToken(req)
+		if ok != test.ok || token != test.token {
+			t.Errorf("BearerToken(%q): expected %q, %v, got %q, %v", test.header, test.token, test.ok, token, ok)
+		}
+	}
+}
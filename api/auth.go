@@ -1,14 +1,121 @@
 package api
 
 import (
-	"fmt"
+	"html/template"
 	"net/http"
 
 	"github.com/julienschmidt/httprouter"
 )
 
-// Auth should be opened in a popup. Will create a new token and return it to
-// the origin window via postMessage
-func Auth(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	fmt.Fprint(w, "Not implemented")
+var (
+	loginPage = template.Must(template.New("login").Parse(`<!doctype html>
+<html>
+  <head><title>Log in</title></head>
+  <body>
+    <h1>Log in</h1>
+    {{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+    <form method="post" action="auth">
+      <input type="hidden" name="origin" value="{{.Origin}}">
+      <p><label>Email <input type="email" name="email" value="{{.Email}}" autofocus></label></p>
+      <p><label>Password <input type="password" name="password"></label></p>
+      <p><label>One time password <input type="text" name="otp" autocomplete="one-time-code" inputmode="numeric"></label> (if enabled)</p>
+      <p><button type="submit">Log in</button></p>
+    </form>
+  </body>
+</html>
+`))
+
+	authorizedPage = template.Must(template.New("authorized").Parse(`<!doctype html>
+<html>
+  <head><title>Logged in</title></head>
+  <body>
+    <p>Logged in, you can close this window.</p>
+    <script>
+      if (window.opener) {
+        window.opener.postMessage({type: "authorization", token: {{.Token}}}, {{.Origin}});
+        window.close();
+      }
+    </script>
+  </body>
+</html>
+`))
+)
+
+type loginPageData struct {
+	Origin string
+	Email  string
+	Error  string
+}
+
+type authorizedPageData struct {
+	Origin string
+	Token  *Token
+}
+
+// Auth should be opened in a popup with the `origin` of the opening window as
+// a query parameter. Serves a login form and will create a new token and
+// return it to the origin window via postMessage. Only origins in the allow
+// list of the API config can receive tokens.
+func (a *API) Auth(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	origin := r.FormValue("origin")
+	if !a.allowedOrigin(origin) {
+		ForbiddenError(w, "Origin not allowed: "+origin)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Header().Set("X-Frame-Options", "DENY")
+
+	if r.Method != "POST" {
+		loginPage.Execute(w, &loginPageData{Origin: origin})
+		return
+	}
+
+	email := r.PostFormValue("email")
+	wait, err := a.loginWait(r, email)
+	if err != nil {
+		w.WriteHeader(500)
+		loginPage.Execute(w, &loginPageData{Origin: origin, Email: email, Error: err.Error()})
+		return
+	}
+	if wait > 0 {
+		retryAfter(w, wait)
+		w.WriteHeader(429)
+		loginPage.Execute(w, &loginPageData{Origin: origin, Email: email, Error: "Too many failed login attempts, try again later"})
+		return
+	}
+
+	token, err := a.resolver.Authenticate(email, r.PostFormValue("password"), r.PostFormValue("otp"))
+	if err == nil {
+		err = a.recordLogin(r, email, token != nil)
+	}
+	if err == ErrOTPRequired {
+		w.WriteHeader(401)
+		loginPage.Execute(w, &loginPageData{Origin: origin, Email: email, Error: err.Error()})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		loginPage.Execute(w, &loginPageData{Origin: origin, Email: email, Error: err.Error()})
+		return
+	}
+	if token == nil {
+		w.WriteHeader(401)
+		loginPage.Execute(w, &loginPageData{Origin: origin, Email: email, Error: "Invalid email or password"})
+		return
+	}
+
+	authorizedPage.Execute(w, &authorizedPageData{Origin: origin, Token: token})
+}
+
+func (a *API) allowedOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, allowed := range a.config.AllowedOrigins {
+		if allowed == origin {
+			return true
+		}
+	}
+	return false
 }
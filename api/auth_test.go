@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAllowedOrigin(t *testing.T) {
+	a := &API{config: &Config{AllowedOrigins: []string{"https://cms.example.com"}}}
+
+	tests := []struct {
+		origin   string
+		expected bool
+	}{
+		{origin: "https://cms.example.com", expected: true},
+		{origin: "https://cms.example.com/", expected: false},
+		{origin: "http://cms.example.com", expected: false},
+		{origin: "https://evil.example.com", expected: false},
+		{origin: "", expected: false},
+	}
+
+	for _, test := range tests {
+		if allowed := a.allowedOrigin(test.origin); allowed != test.expected {
+			t.Errorf("allowedOrigin(%q): expected %v, got %v", test.origin, test.expected, allowed)
+		}
+	}
+}
+
+func TestAuth(t *testing.T) {
+	a := &API{
+		resolver: &testResolver{password: "secret", otp: "123456"},
+		config:   &Config{AllowedOrigins: []string{"https://cms.example.com"}},
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		origin   string
+		form     url.Values
+		status   int
+		contains string
+	}{
+		{name: "login form", method: "GET", origin: "https://cms.example.com", status: 200, contains: `name="password"`},
+		{name: "origin not allowed", method: "GET", origin: "https://evil.example.com", status: 403},
+		{name: "missing origin", method: "GET", status: 403},
+		{
+			name:     "wrong password",
+			method:   "POST",
+			origin:   "https://cms.example.com",
+			form:     url.Values{"email": {"test@example.com"}, "password": {"wrong"}},
+			status:   401,
+			contains: "Invalid email or password",
+		},
+		{
+			name:     "missing otp",
+			method:   "POST",
+			origin:   "https://cms.example.com",
+			form:     url.Values{"email": {"test@example.com"}, "password": {"secret"}},
+			status:   401,
+			contains: ErrOTPRequired.Error(),
+		},
+		{
+			name:     "logged in",
+			method:   "POST",
+			origin:   "https://cms.example.com",
+			form:     url.Values{"email": {"test@example.com"}, "password": {"secret"}, "otp": {"123456"}},
+			status:   200,
+			contains: `"access-token"`,
+		},
+		{
+			name:   "post to other origin",
+			method: "POST",
+			origin: "https://evil.example.com",
+			form:   url.Values{"email": {"test@example.com"}, "password": {"secret"}, "otp": {"123456"}},
+			status: 403,
+		},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, "/auth?origin="+url.QueryEscape(test.origin), strings.NewReader(test.form.Encode()))
+		if test.method == "POST" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		w := httptest.NewRecorder()
+		a.Auth(w, req, nil)
+
+		if w.Code != test.status {
+			t.Errorf("%v: expected status %v, got %v: %v", test.name, test.status, w.Code, w.Body.String())
+			continue
+		}
+		if test.contains != "" && !strings.Contains(w.Body.String(), test.contains) {
+			t.Errorf("%v: expected the response to contain %q, got %v", test.name, test.contains, w.Body.String())
+		}
+		if test.status == 200 && w.Header().Get("X-Frame-Options") != "DENY" {
+			t.Errorf("%v: expected the page to deny framing", test.name)
+		}
+		if test.status != 200 && strings.Contains(w.Body.String(), "access-token") {
+			t.Errorf("%v: expected no token in the response", test.name)
+		}
+	}
+}
@@ -36,12 +36,12 @@ func CreateBlob(w http.ResponseWriter, r *http.Request, params httprouter.Params
 		jsonDecoder := json.NewDecoder(r.Body)
 		err := jsonDecoder.Decode(blobParams)
 		if err != nil {
-			InternalServerError(w, fmt.Sprintf("Could not parse blob params: %v", err))
+			BadRequestError(w, fmt.Sprintf("Could not parse blob params: %v", err))
 			return
 		}
 
 		if blobParams.Encoding != "base64" {
-			InternalServerError(w, fmt.Sprintf("Only base64 encoding supported. Encoding set to: %v", blobParams.Encoding))
+			BadRequestError(w, fmt.Sprintf("Only base64 encoding supported. Encoding set to: %v", blobParams.Encoding))
 			return
 		}
 
@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/netlify/netlify-git-api/repo"
 	"golang.org/x/net/context"
 )
 
@@ -16,6 +19,33 @@ type CommitCreateParams struct {
 	Parents []string `json:"parents"`
 }
 
+// BatchOperation is a single file change in a batch commit.
+// Action can be "add", "update", "delete" or "move". A move takes the file at
+// `previous_path` to `path`, keeping its contents unless `content` is set.
+// If `sha` is set it must match the current file (the moved file for moves).
+type BatchOperation struct {
+	Action       string `json:"action"`
+	Path         string `json:"path"`
+	PreviousPath string `json:"previous_path"`
+	Content      string `json:"content"`
+	Encoding     string `json:"encoding"`
+	Sha          string `json:"sha"`
+}
+
+// BatchCommitParams is the JSON object sent when committing several file changes
+type BatchCommitParams struct {
+	Branch     string            `json:"branch"`
+	Parent     string            `json:"parent"`
+	Message    string            `json:"message"`
+	Operations []*BatchOperation `json:"operations"`
+}
+
+// BatchCommitResponse is the JSON object returned after a batch commit
+type BatchCommitResponse struct {
+	Commit *repo.Commit    `json:"commit"`
+	Ref    *repo.Reference `json:"ref"`
+}
+
 // CreateCommit creates a new commit
 func CreateCommit(w http.ResponseWriter, r *http.Request, params httprouter.Params, ctx context.Context) {
 	currentRepo := getRepo(ctx)
@@ -23,7 +53,7 @@ func CreateCommit(w http.ResponseWriter, r *http.Request, params httprouter.Para
 	jsonDecoder := json.NewDecoder(r.Body)
 	err := jsonDecoder.Decode(commitParams)
 	if err != nil {
-		InternalServerError(w, fmt.Sprintf("Could not read commit creation params: %v", err))
+		BadRequestError(w, fmt.Sprintf("Could not read commit creation params: %v", err))
 		return
 	}
 
@@ -40,6 +70,225 @@ func CreateCommit(w http.ResponseWriter, r *http.Request, params httprouter.Para
 	sendJSON(w, 200, commit)
 }
 
+// CreateBatchCommit applies a list of file operations to a branch in a single
+// commit. If `parent` is set it must match the current head of the branch.
+// Either all operations are committed and the branch is updated or nothing
+// changes. Responds with 409 if the branch moves while the commit is created.
+func CreateBatchCommit(w http.ResponseWriter, r *http.Request, params httprouter.Params, ctx context.Context) {
+	currentRepo := getRepo(ctx)
+	batchParams := &BatchCommitParams{}
+	jsonDecoder := json.NewDecoder(r.Body)
+	err := jsonDecoder.Decode(batchParams)
+	if err != nil {
+		BadRequestError(w, fmt.Sprintf("Could not read batch commit params: %v", err))
+		return
+	}
+
+	if len(batchParams.Operations) == 0 {
+		BadRequestError(w, "No operations in batch commit")
+		return
+	}
+
+	refName := "refs/heads/" + batchParams.Branch
+	ref, err := currentRepo.GetRef(refName)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+	if batchParams.Parent != "" && batchParams.Parent != ref.Object.Sha {
+		ConflictError(w, fmt.Sprintf("Branch %v has moved, current sha is %v", batchParams.Branch, ref.Object.Sha))
+		return
+	}
+
+	commit, err := currentRepo.GetCommit(ref.Object.Sha)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	treeSha := commit.Tree.Sha
+	for _, op := range batchParams.Operations {
+		var ok bool
+		treeSha, ok = applyOperation(w, currentRepo, treeSha, op)
+		if !ok {
+			return
+		}
+	}
+
+	newCommit, err := currentRepo.CreateCommit(treeSha, batchParams.Message, []string{ref.Object.Sha})
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	newRef, err := currentRepo.UpdateRefFrom(refName, ref.Object.Sha, newCommit.Sha, false)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	sendJSON(w, 201, &BatchCommitResponse{Commit: newCommit, Ref: newRef})
+}
+
+// applyOperation writes a new tree with the operation applied to the tree with
+// treeSha. Sends an error response and returns false if the operation fails.
+func applyOperation(w http.ResponseWriter, currentRepo *repo.Repo, treeSha string, op *BatchOperation) (string, bool) {
+	pathname := strings.Trim(op.Path, "/")
+	if pathname == "" {
+		BadRequestError(w, fmt.Sprintf("Missing path for %v operation", op.Action))
+		return "", false
+	}
+
+	var tree *repo.Tree
+	var err error
+	switch op.Action {
+	case "add":
+		existing, ok := currentFile(w, currentRepo, treeSha, pathname, "", "")
+		if !ok {
+			return "", false
+		}
+		if existing != nil {
+			shaConflict(w, fmt.Sprintf("File %v already exists", pathname), existing.Sha)
+			return "", false
+		}
+		blobSha, ok := putContent(w, currentRepo, op)
+		if !ok {
+			return "", false
+		}
+		tree, err = currentRepo.UpdateTreePath(treeSha, pathname, blobSha, "")
+	case "update":
+		existing, ok := existingFile(w, currentRepo, treeSha, pathname, op.Sha)
+		if !ok {
+			return "", false
+		}
+		blobSha, ok := putContent(w, currentRepo, op)
+		if !ok {
+			return "", false
+		}
+		tree, err = currentRepo.UpdateTreePath(treeSha, pathname, blobSha, existing.Mode)
+	case "delete":
+		if _, ok := existingFile(w, currentRepo, treeSha, pathname, op.Sha); !ok {
+			return "", false
+		}
+		tree, err = currentRepo.UpdateTreePath(treeSha, pathname, "", "")
+	case "move":
+		previous := strings.Trim(op.PreviousPath, "/")
+		existing, ok := existingFile(w, currentRepo, treeSha, previous, op.Sha)
+		if !ok {
+			return "", false
+		}
+		target, ok := currentFile(w, currentRepo, treeSha, pathname, "", "")
+		if !ok {
+			return "", false
+		}
+		if target != nil {
+			shaConflict(w, fmt.Sprintf("File %v already exists", pathname), target.Sha)
+			return "", false
+		}
+		blobSha := existing.Sha
+		if op.Content != "" {
+			blobSha, ok = putContent(w, currentRepo, op)
+			if !ok {
+				return "", false
+			}
+		}
+		tree, err = currentRepo.UpdateTreePath(treeSha, previous, "", "")
+		if err == nil {
+			tree, err = currentRepo.UpdateTreePath(tree.Sha, pathname, blobSha, existing.Mode)
+		}
+	default:
+		BadRequestError(w, fmt.Sprintf("Unknown batch operation: %v", op.Action))
+		return "", false
+	}
+
+	if err != nil {
+		HandleError(w, err)
+		return "", false
+	}
+	return tree.Sha, true
+}
+
+// existingFile is like currentFile but fails if the file doesn't exist
+func existingFile(w http.ResponseWriter, currentRepo *repo.Repo, treeSha, pathname, sha string) (*repo.TreeEntry, bool) {
+	existing, ok := currentFile(w, currentRepo, treeSha, pathname, sha, "")
+	if !ok {
+		return nil, false
+	}
+	if existing == nil {
+		shaConflict(w, fmt.Sprintf("File %v does not exist", pathname), "")
+		return nil, false
+	}
+	return existing, true
+}
+
+// putContent stores the content of an operation as a blob and returns its sha
+func putContent(w http.ResponseWriter, currentRepo *repo.Repo, op *BatchOperation) (string, bool) {
+	reader, err := contentReader(op.Content, op.Encoding)
+	if err != nil {
+		BadRequestError(w, err.Error())
+		return "", false
+	}
+
+	blob, err := currentRepo.PutBlob(reader)
+	if err != nil {
+		HandleError(w, err)
+		return "", false
+	}
+	return blob.Sha, true
+}
+
+// ListCommits returns the commit history starting at the `sha` query parameter
+// (a branch, tag or sha, defaults to HEAD). The history can be filtered with
+// `path`, `author`, `since` and `until` (RFC 3339 timestamps) and is paginated
+// with `page` and `per_page`.
+func ListCommits(w http.ResponseWriter, r *http.Request, params httprouter.Params, ctx context.Context) {
+	currentRepo := getRepo(ctx)
+	query := r.URL.Query()
+
+	filter := &repo.CommitFilter{
+		Path:   strings.Trim(query.Get("path"), "/"),
+		Author: query.Get("author"),
+	}
+
+	var err error
+	if since := query.Get("since"); since != "" {
+		filter.Since, err = time.Parse(time.RFC3339, since)
+		if err != nil {
+			BadRequestError(w, fmt.Sprintf("Bad since parameter: %v", err))
+			return
+		}
+	}
+	if until := query.Get("until"); until != "" {
+		filter.Until, err = time.Parse(time.RFC3339, until)
+		if err != nil {
+			BadRequestError(w, fmt.Sprintf("Bad until parameter: %v", err))
+			return
+		}
+	}
+
+	page, err := intParam(query.Get("page"), 1)
+	if err != nil || page < 1 {
+		BadRequestError(w, fmt.Sprintf("Bad page parameter: %v", query.Get("page")))
+		return
+	}
+	perPage, err := intParam(query.Get("per_page"), defaultPerPage)
+	if err != nil || perPage < 1 {
+		BadRequestError(w, fmt.Sprintf("Bad per_page parameter: %v", query.Get("per_page")))
+		return
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+
+	commits, err := currentRepo.ListCommits(query.Get("sha"), filter, (page-1)*perPage, perPage)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	sendJSON(w, 200, commits)
+}
+
 // GetCommit returns a single commit object
 func GetCommit(w http.ResponseWriter, r *http.Request, params httprouter.Params, ctx context.Context) {
 	currentRepo := getRepo(ctx)
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/netlify/netlify-git-api/repo"
+)
+
+func TestListCommits(t *testing.T) {
+	tr := newTestRepo(t, map[string]string{"README.md": "v1"})
+	defer tr.Close()
+
+	shas := []string{tr.refSha(t, "refs/heads/master")}
+	for i := 0; i < 4; i++ {
+		shas = append([]string{tr.commit(t, shas[0], map[string]string{"README.md": strconv.Itoa(i)}).Sha}, shas...)
+	}
+	tr.setRef(t, "refs/heads/master", shas[0])
+
+	tests := []struct {
+		query   string
+		status  int
+		commits []string
+	}{
+		{query: "", status: 200, commits: shas},
+		{query: "?per_page=2", status: 200, commits: shas[:2]},
+		{query: "?per_page=2&page=2", status: 200, commits: shas[2:4]},
+		{query: "?per_page=2&page=3", status: 200, commits: shas[4:]},
+		{query: "?sha=" + shas[3], status: 200, commits: shas[3:]},
+		{query: "?since=2000-01-01T00:00:00Z", status: 200, commits: shas},
+		{query: "?until=2000-01-01T00:00:00Z", status: 200, commits: []string{}},
+		{query: "?page=0", status: 400},
+		{query: "?per_page=x", status: 400},
+		{query: "?since=yesterday", status: 400},
+		{query: "?sha=nope", status: 404},
+	}
+
+	for _, test := range tests {
+		w := tr.serve(ListCommits, "GET", "/commits"+test.query, "", nil, nil)
+		if w.Code != test.status {
+			t.Errorf("GET /commits%v: expected status %v, got %v: %v", test.query, test.status, w.Code, w.Body.String())
+			continue
+		}
+		if test.status != 200 {
+			continue
+		}
+
+		commits := []*repo.Commit{}
+		if err := json.NewDecoder(w.Body).Decode(&commits); err != nil {
+			t.Errorf("GET /commits%v: error decoding response: %v", test.query, err)
+			continue
+		}
+		if len(commits) != len(test.commits) {
+			t.Errorf("GET /commits%v: expected %v commits, got %v", test.query, len(test.commits), len(commits))
+			continue
+		}
+		for i, commit := range commits {
+			if commit.Sha != test.commits[i] {
+				t.Errorf("GET /commits%v: expected commit %v to be %v, got %v", test.query, i, test.commits[i], commit.Sha)
+			}
+		}
+	}
+}
+
+func TestCreateBatchCommit(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+		files  map[string]string
+	}{
+		{
+			name:   "all operations",
+			body:   `{"branch": "master", "parent": "{{master}}", "message": "Batch", "operations": [{"action": "add", "path": "new.md", "content": "new"}, {"action": "update", "path": "README.md", "content": "updated", "sha": "{{README.md}}"}, {"action": "delete", "path": "delete.md"}, {"action": "move", "path": "moved/file.md", "previous_path": "move.md"}]}`,
+			status: 201,
+			files:  map[string]string{"new.md": "new", "README.md": "updated", "delete.md": "", "move.md": "", "moved/file.md": "move"},
+		},
+		{
+			name:   "move with new content",
+			body:   `{"branch": "master", "operations": [{"action": "move", "path": "moved.md", "previous_path": "move.md", "content": "changed"}]}`,
+			status: 201,
+			files:  map[string]string{"move.md": "", "moved.md": "changed"},
+		},
+		{
+			name:   "stale parent",
+			body:   `{"branch": "master", "parent": "{{README.md}}", "operations": [{"action": "add", "path": "new.md", "content": "new"}]}`,
+			status: 409,
+			code:   "conflict",
+		},
+		{
+			name:   "add existing file",
+			body:   `{"branch": "master", "operations": [{"action": "add", "path": "new.md", "content": "new"}, {"action": "add", "path": "README.md", "content": "x"}]}`,
+			status: 409,
+			code:   "sha_mismatch",
+		},
+		{
+			name:   "update missing file",
+			body:   `{"branch": "master", "operations": [{"action": "update", "path": "missing.md", "content": "x"}]}`,
+			status: 409,
+			code:   "sha_mismatch",
+		},
+		{
+			name:   "update with stale sha",
+			body:   `{"branch": "master", "operations": [{"action": "update", "path": "README.md", "content": "x", "sha": "{{move.md}}"}]}`,
+			status: 409,
+			code:   "sha_mismatch",
+		},
+		{
+			name:   "move onto existing file",
+			body:   `{"branch": "master", "operations": [{"action": "move", "path": "README.md", "previous_path": "move.md"}]}`,
+			status: 409,
+			code:   "sha_mismatch",
+		},
+		{
+			name:   "unknown action",
+			body:   `{"branch": "master", "operations": [{"action": "copy", "path": "x.md"}]}`,
+			status: 400,
+			code:   "bad_request",
+		},
+		{
+			name:   "bad base64",
+			body:   `{"branch": "master", "operations": [{"action": "add", "path": "new.md", "content": "not base64!", "encoding": "base64"}]}`,
+			status: 400,
+			code:   "bad_request",
+		},
+		{
+			name:   "missing path",
+			body:   `{"branch": "master", "operations": [{"action": "add", "content": "x"}]}`,
+			status: 400,
+			code:   "bad_request",
+		},
+		{
+			name:   "no operations",
+			body:   `{"branch": "master", "operations": []}`,
+			status: 400,
+			code:   "bad_request",
+		},
+		{
+			name:   "missing branch",
+			body:   `{"branch": "nope", "operations": [{"action": "add", "path": "new.md", "content": "new"}]}`,
+			status: 404,
+			code:   "not_found",
+		},
+	}
+
+	for _, test := range tests {
+		tr := newTestRepo(t, map[string]string{"README.md": "readme", "delete.md": "delete", "move.md": "move"})
+		before := tr.refSha(t, "refs/heads/master")
+		body := tr.expand(strings.Replace(test.body, "{{master}}", before, -1))
+
+		w := tr.serve(CreateBatchCommit, "POST", "/commits/batch", body, nil, nil)
+		if w.Code != test.status {
+			t.Errorf("%v: expected status %v, got %v: %v", test.name, test.status, w.Code, w.Body.String())
+			tr.Close()
+			continue
+		}
+
+		after := tr.refSha(t, "refs/heads/master")
+		if test.status != 201 {
+			if e := decodeError(t, w); e.Code != test.code {
+				t.Errorf("%v: expected code %v, got %v", test.name, test.code, e.Code)
+			}
+			if after != before {
+				t.Errorf("%v: expected master to stay at %v, got %v", test.name, before, after)
+			}
+			tr.Close()
+			continue
+		}
+
+		response := &BatchCommitResponse{}
+		if err := json.NewDecoder(w.Body).Decode(response); err != nil {
+			t.Errorf("%v: error decoding response: %v", test.name, err)
+		} else if response.Ref.Object.Sha != after || response.Commit.Sha != after {
+			t.Errorf("%v: expected master to point to the new commit, got %v", test.name, after)
+		} else if len(response.Commit.Parents) != 1 || response.Commit.Parents[0].Sha != before {
+			t.Errorf("%v: expected the commit to have %v as its parent", test.name, before)
+		}
+		for pathname, content := range test.files {
+			if content == "" {
+				if sha := tr.fileSha(pathname); sha != "" {
+					t.Errorf("%v: expected %v to be removed", test.name, pathname)
+				}
+				continue
+			}
+			if actual := tr.fileContent(t, pathname); actual != content {
+				t.Errorf("%v: expected %v to be %q, got %q", test.name, pathname, content, actual)
+			}
+		}
+		tr.Close()
+	}
+}
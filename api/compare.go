@@ -0,0 +1,31 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/net/context"
+)
+
+// Compare returns the difference between two commits given as `base...head`
+// (branches, tags or shas). Unified patches are included for each file unless
+// the `patch` query parameter is set to false.
+func Compare(w http.ResponseWriter, r *http.Request, params httprouter.Params, ctx context.Context) {
+	currentRepo := getRepo(ctx)
+	basehead := params.ByName("basehead")[1:]
+	parts := strings.SplitN(basehead, "...", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		NotFoundError(w, fmt.Sprintf("Not a valid comparison: %v", basehead))
+		return
+	}
+
+	comparison, err := currentRepo.Compare(parts[0], parts[1], r.URL.Query().Get("patch") != "false")
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	sendJSON(w, 200, comparison)
+}
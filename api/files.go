@@ -1,6 +1,8 @@
 package api
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -20,13 +22,30 @@ type FileDeleteParams struct {
 	Branch  string `json:"branch"`
 }
 
+// FileUpdateParams holds the parameters for UpdateFile request
+type FileUpdateParams struct {
+	Content  string `json:"content"`
+	Encoding string `json:"encoding"`
+	Sha      string `json:"sha"`
+	Message  string `json:"message"`
+	Branch   string `json:"branch"`
+}
+
+// FileUpdateResponse is the JSON object returned after updating a file
+type FileUpdateResponse struct {
+	Content *repo.File   `json:"content"`
+	Commit  *repo.Commit `json:"commit"`
+}
+
 // GetFile returns information about a file or directory in the repository.
 // If the Content-Type is set to "application/vnd.netlify.raw" it will return
-// the actual file contents (or an error if a directory)
+// the actual file contents (or an error if a directory).
+// Takes an optional `ref` query parameter (branch, tag or commit sha) to read
+// the file at a specific revision instead of the current HEAD.
 func GetFile(w http.ResponseWriter, r *http.Request, params httprouter.Params, ctx context.Context) {
 	currentRepo := getRepo(ctx)
 	pathname := params.ByName("path")[1:]
-	file, err := currentRepo.GetFile(pathname)
+	file, err := currentRepo.GetFileAt(r.URL.Query().Get("ref"), pathname)
 	if err != nil {
 		HandleError(w, err)
 		return
@@ -37,8 +56,10 @@ func GetFile(w http.ResponseWriter, r *http.Request, params httprouter.Params, c
 		return
 	}
 
+	w.Header().Set("ETag", fmt.Sprintf("\"%v\"", file.Sha))
 	if r.Header.Get("Content-Type") != rawContentType {
 		sendJSON(w, 200, file)
+		return
 	}
 
 	blob, err := currentRepo.GetBlob(file.Sha)
@@ -49,87 +70,229 @@ func GetFile(w http.ResponseWriter, r *http.Request, params httprouter.Params, c
 	io.Copy(w, blob)
 }
 
-// DeleteFile deletes a file from the repo
-// Takes a `sha`, a `message` for the commit message and the path to the file
-func DeleteFile(w http.ResponseWriter, r *http.Request, params httprouter.Params, ctx context.Context) {
+// UpdateFile creates or updates a file in the repo and commits the change to a branch.
+// Takes the `content` (with `base64` as optional `encoding`), a `message` for the
+// commit message, the `branch` and the `sha` of the file being replaced.
+// If the Content-Type is set to "application/vnd.netlify.raw" the body is used as
+// the file contents and the other parameters are read from the query string.
+// Responds with 409 if the branch moves while the commit is created.
+func UpdateFile(w http.ResponseWriter, r *http.Request, params httprouter.Params, ctx context.Context) {
 	currentRepo := getRepo(ctx)
 	pathname := params.ByName("path")[1:]
 
-	fileParams := &FileDeleteParams{}
-	jsonDecoder := json.NewDecoder(r.Body)
-	err := jsonDecoder.Decode(fileParams)
+	fileParams := &FileUpdateParams{}
+	var reader io.Reader
+	if r.Header.Get("Content-Type") == rawContentType {
+		query := r.URL.Query()
+		fileParams.Sha = query.Get("sha")
+		fileParams.Message = query.Get("message")
+		fileParams.Branch = query.Get("branch")
+		reader = r.Body
+	} else {
+		jsonDecoder := json.NewDecoder(r.Body)
+		err := jsonDecoder.Decode(fileParams)
+		if err != nil {
+			BadRequestError(w, fmt.Sprintf("Could not read file update params: %v", err))
+			return
+		}
+
+		reader, err = contentReader(fileParams.Content, fileParams.Encoding)
+		if err != nil {
+			BadRequestError(w, err.Error())
+			return
+		}
+	}
+
+	refName := "refs/heads/" + fileParams.Branch
+	ref, err := currentRepo.GetRef(refName)
 	if err != nil {
-		InternalServerError(w, fmt.Sprintf("Could not read file deletiong params: %v", err))
+		HandleError(w, err)
+		return
+	}
+	commit, err := currentRepo.GetCommit(ref.Object.Sha)
+	if err != nil {
+		HandleError(w, err)
 		return
 	}
 
-	ref, err := currentRepo.GetRef("refs/heads/" + fileParams.Branch)
+	existing, ok := currentFile(w, currentRepo, commit.Tree.Sha, pathname, fileParams.Sha, r.Header.Get("If-Match"))
+	if !ok {
+		return
+	}
+
+	var mode string
+	if existing != nil {
+		if fileParams.Sha == "" && r.Header.Get("If-Match") == "" {
+			shaConflict(w, fmt.Sprintf("File %v already exists, the sha of the file being replaced is required", pathname), existing.Sha)
+			return
+		}
+		mode = existing.Mode
+	}
+
+	blob, err := currentRepo.PutBlob(reader)
 	if err != nil {
 		HandleError(w, err)
 		return
 	}
-	commit, err := currentRepo.GetCommit(ref.Object.Sha)
+
+	newTree, err := currentRepo.UpdateTreePath(commit.Tree.Sha, pathname, blob.Sha, mode)
 	if err != nil {
 		HandleError(w, err)
 		return
 	}
 
-	tree, err := currentRepo.GetTree(commit.Tree.Sha)
+	newCommit, err := currentRepo.CreateCommit(newTree.Sha, fileParams.Message, []string{ref.Object.Sha})
 	if err != nil {
 		HandleError(w, err)
 		return
 	}
 
-	dir, name := path.Split(pathname)
-	segments := strings.Split(dir, "/")
-	trees := []*repo.Tree{tree}
-	for _, segment := range segments {
-		if segment == "" {
-			continue
-		}
-		for _, entry := range tree.Tree {
-			if entry.Path == segment {
-				tree, err = currentRepo.GetTree(entry.Sha)
-				if err != nil {
-					HandleError(w, err)
-					return
-				}
-				trees = append(trees, tree)
-				break
-			}
-		}
+	if _, err := currentRepo.UpdateRefFrom(refName, ref.Object.Sha, newCommit.Sha, false); err != nil {
+		HandleError(w, err)
+		return
 	}
 
-	newEntries := []*repo.TreeEntry{}
-	for _, entry := range tree.Tree {
-		if entry.Path != name {
-			newEntries = append(newEntries, entry)
-		}
+	sendJSON(w, 200, &FileUpdateResponse{
+		Content: &repo.File{
+			Name: path.Base(pathname),
+			Path: pathname,
+			Size: blob.Size,
+			Sha:  blob.Sha,
+			Type: "file",
+		},
+		Commit: newCommit,
+	})
+}
+
+// DeleteFile deletes a file from the repo
+// Takes a `sha`, a `message` for the commit message and the path to the file.
+// Either the `sha` or an If-Match header is required and the request is
+// rejected if it doesn't match the current file. Responds with 409 if the
+// branch moves while the commit is created.
+func DeleteFile(w http.ResponseWriter, r *http.Request, params httprouter.Params, ctx context.Context) {
+	currentRepo := getRepo(ctx)
+	pathname := params.ByName("path")[1:]
+
+	fileParams := &FileDeleteParams{}
+	jsonDecoder := json.NewDecoder(r.Body)
+	err := jsonDecoder.Decode(fileParams)
+	if err != nil {
+		BadRequestError(w, fmt.Sprintf("Could not read file deletiong params: %v", err))
+		return
+	}
+	if fileParams.Sha == "" && r.Header.Get("If-Match") == "" {
+		UnprocessableEntityError(w, "The sha of the file being deleted is required")
+		return
 	}
-	newTree, err := currentRepo.CreateTree("", newEntries)
 
-	for i := len(trees) - 1; i > 0; i-- {
-		tree = trees[i-1]
-		name = segments[i-1]
-		for _, entry := range tree.Tree {
-			if entry.Path == name {
-				entry.Sha = newTree.Sha
-			}
-		}
-		newTree, err = currentRepo.CreateTree("", tree.Tree)
+	refName := "refs/heads/" + fileParams.Branch
+	ref, err := currentRepo.GetRef(refName)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+	commit, err := currentRepo.GetCommit(ref.Object.Sha)
+	if err != nil {
+		HandleError(w, err)
+		return
 	}
 
-	newCommit, err := currentRepo.CreateCommit(newTree.Sha, fileParams.Message, []string{ref.Object.Sha})
+	if _, ok := currentFile(w, currentRepo, commit.Tree.Sha, pathname, fileParams.Sha, r.Header.Get("If-Match")); !ok {
+		return
+	}
+
+	newTree, err := currentRepo.UpdateTreePath(commit.Tree.Sha, pathname, "", "")
 	if err != nil {
 		HandleError(w, err)
 		return
 	}
 
-	newRef, err := currentRepo.UpdateRef("refs/heads/"+fileParams.Branch, newCommit.Sha)
+	newCommit, err := currentRepo.CreateCommit(newTree.Sha, fileParams.Message, []string{ref.Object.Sha})
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
 
+	newRef, err := currentRepo.UpdateRefFrom(refName, ref.Object.Sha, newCommit.Sha, false)
 	if err != nil {
 		HandleError(w, err)
 		return
 	}
 	sendJSON(w, 200, newRef)
 }
+
+// currentFile looks up the file at pathname in a tree and checks it against the
+// sha sent by the client and an If-Match header value. Returns nil if the file
+// doesn't exist. Sends an error response and returns false if a precondition fails.
+func currentFile(w http.ResponseWriter, currentRepo *repo.Repo, treeSha, pathname, sha, ifMatch string) (*repo.TreeEntry, bool) {
+	var current string
+	entry, err := currentRepo.GetTreeEntry(treeSha, pathname)
+	switch err.(type) {
+	case nil:
+		if entry.Type != "blob" {
+			UnprocessableEntityError(w, fmt.Sprintf("Not a file: %v", pathname))
+			return nil, false
+		}
+		current = entry.Sha
+	case *repo.NotFoundError:
+		entry = nil
+	default:
+		HandleError(w, err)
+		return nil, false
+	}
+
+	if sha != "" && sha != current {
+		shaConflict(w, fmt.Sprintf("File %v has changed, the sha %v does not match", pathname, sha), current)
+		return nil, false
+	}
+
+	if ifMatch != "" && !matchesETag(ifMatch, current) {
+		preconditionFailed(w, fmt.Sprintf("File %v has changed, If-Match %v does not match", pathname, ifMatch), current)
+		return nil, false
+	}
+
+	return entry, true
+}
+
+// matchesETag checks a list of ETags from an If-Match header against a sha
+func matchesETag(header, sha string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" && sha != "" {
+			return true
+		}
+		tag = strings.Trim(strings.TrimPrefix(tag, "W/"), "\"")
+		if tag != "" && tag == sha {
+			return true
+		}
+	}
+	return false
+}
+
+// preconditionFailed sends a failed If-Match precondition with the current sha
+// of the file (empty if missing)
+func preconditionFailed(w http.ResponseWriter, msg, sha string) {
+	sendError(w, 412, "precondition_failed", msg, map[string]string{"sha": sha})
+}
+
+// shaConflict sends a conflict with the current sha of the file (empty if missing)
+func shaConflict(w http.ResponseWriter, msg, sha string) {
+	sendError(w, 409, "sha_mismatch", msg, map[string]string{"sha": sha})
+}
+
+// contentReader decodes file content sent as part of a JSON object. base64
+// content is decoded right away so invalid content is a bad request.
+func contentReader(content, encoding string) (io.Reader, error) {
+	switch encoding {
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(content)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid base64 content: %v", err)
+		}
+		return bytes.NewReader(data), nil
+	case "", "utf-8":
+		return bytes.NewBufferString(content), nil
+	default:
+		return nil, fmt.Errorf("Unsupported encoding: %v", encoding)
+	}
+}
@@ -0,0 +1,270 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGetFile(t *testing.T) {
+	tr := newTestRepo(t, map[string]string{"README.md": "v1"})
+	defer tr.Close()
+
+	master := tr.refSha(t, "refs/heads/master")
+	tr.setRef(t, "refs/heads/feature", tr.commit(t, master, map[string]string{"README.md": "v2"}).Sha)
+
+	tests := []struct {
+		url     string
+		status  int
+		content string
+	}{
+		{url: "/files/README.md", status: 200, content: "v1"},
+		{url: "/files/README.md?ref=master", status: 200, content: "v1"},
+		{url: "/files/README.md?ref=feature", status: 200, content: "v2"},
+		{url: "/files/README.md?ref=" + master, status: 200, content: "v1"},
+		{url: "/files/README.md?ref=nope", status: 404},
+		{url: "/files/missing.md?ref=feature", status: 404},
+	}
+
+	for _, test := range tests {
+		header := http.Header{}
+		header.Set("Content-Type", rawContentType)
+		pathname := strings.SplitN(test.url[len("/files/"):], "?", 2)[0]
+
+		w := tr.serve(GetFile, "GET", test.url, "", header, pathParams(pathname))
+		if w.Code != test.status {
+			t.Errorf("GET %v: expected status %v, got %v", test.url, test.status, w.Code)
+			continue
+		}
+		if test.status == 200 && w.Body.String() != test.content {
+			t.Errorf("GET %v: expected %q, got %q", test.url, test.content, w.Body.String())
+		}
+	}
+}
+
+func TestUpdateFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		body    string
+		raw     bool
+		status  int
+		content string
+	}{
+		{
+			name:    "create",
+			url:     "/files/content/new.md",
+			body:    `{"content": "new file", "message": "Create", "branch": "master"}`,
+			status:  200,
+			content: "new file",
+		},
+		{
+			name:    "base64",
+			url:     "/files/content/new.md",
+			body:    `{"content": "aGVsbG8=", "encoding": "base64", "message": "Create", "branch": "master"}`,
+			status:  200,
+			content: "hello",
+		},
+		{
+			name:    "update",
+			url:     "/files/README.md",
+			body:    `{"content": "updated", "sha": "{{README.md}}", "message": "Update", "branch": "master"}`,
+			status:  200,
+			content: "updated",
+		},
+		{
+			name:    "raw",
+			url:     "/files/README.md?branch=master&message=Raw&sha={{README.md}}",
+			body:    "raw content",
+			raw:     true,
+			status:  200,
+			content: "raw content",
+		},
+		{
+			name:   "bad json",
+			url:    "/files/README.md",
+			body:   `{"content": `,
+			status: 400,
+		},
+		{
+			name:   "bad encoding",
+			url:    "/files/new.md",
+			body:   `{"content": "x", "encoding": "rot13", "branch": "master"}`,
+			status: 400,
+		},
+		{
+			name:   "bad base64",
+			url:    "/files/new.md",
+			body:   `{"content": "not base64!", "encoding": "base64", "branch": "master"}`,
+			status: 400,
+		},
+		{
+			name:   "missing branch",
+			url:    "/files/new.md",
+			body:   `{"content": "x", "branch": "nope"}`,
+			status: 404,
+		},
+		{
+			name:   "directory",
+			url:    "/files/content",
+			body:   `{"content": "x", "branch": "master", "sha": "{{content}}"}`,
+			status: 422,
+		},
+		{
+			name:   "file as directory",
+			url:    "/files/README.md/nested.md",
+			body:   `{"content": "x", "branch": "master"}`,
+			status: 409,
+		},
+	}
+
+	for _, test := range tests {
+		tr := newTestRepo(t, map[string]string{"README.md": "readme", "content/post.md": "post"})
+		header := http.Header{}
+		if test.raw {
+			header.Set("Content-Type", rawContentType)
+		}
+		pathname := strings.SplitN(test.url[len("/files/"):], "?", 2)[0]
+
+		w := tr.serve(UpdateFile, "PUT", tr.expand(test.url), tr.expand(test.body), header, pathParams(pathname))
+		if w.Code != test.status {
+			t.Errorf("%v: expected status %v, got %v: %v", test.name, test.status, w.Code, w.Body.String())
+			tr.Close()
+			continue
+		}
+
+		if test.status == 200 {
+			response := &FileUpdateResponse{}
+			if err := json.NewDecoder(w.Body).Decode(response); err != nil {
+				t.Errorf("%v: error decoding response: %v", test.name, err)
+			} else if response.Content.Sha != tr.fileSha(pathname) {
+				t.Errorf("%v: expected the response sha %v to match the file %v", test.name, response.Content.Sha, tr.fileSha(pathname))
+			} else if tr.refSha(t, "refs/heads/master") != response.Commit.Sha {
+				t.Errorf("%v: expected master to point to the new commit %v", test.name, response.Commit.Sha)
+			}
+
+			if content := tr.fileContent(t, pathname); content != test.content {
+				t.Errorf("%v: expected content %q, got %q", test.name, test.content, content)
+			}
+		}
+		tr.Close()
+	}
+}
+
+func TestContentReader(t *testing.T) {
+	tests := []struct {
+		content  string
+		encoding string
+		expected string
+		err      bool
+	}{
+		{content: "plain", encoding: "", expected: "plain"},
+		{content: "plain", encoding: "utf-8", expected: "plain"},
+		{content: "aGVsbG8gd29ybGQ=", encoding: "base64", expected: "hello world"},
+		{content: "plain", encoding: "latin1", err: true},
+		{content: "not base64!", encoding: "base64", err: true},
+	}
+
+	for _, test := range tests {
+		reader, err := contentReader(test.content, test.encoding)
+		if test.err {
+			if err == nil {
+				t.Errorf("contentReader(%q, %q): expected an error", test.content, test.encoding)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("contentReader(%q, %q): unexpected error %v", test.content, test.encoding, err)
+			continue
+		}
+		data, _ := ioutil.ReadAll(reader)
+		if string(data) != test.expected {
+			t.Errorf("contentReader(%q, %q): expected %q, got %q", test.content, test.encoding, test.expected, data)
+		}
+	}
+}
+
+func TestFilePreconditions(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		ifMatch string
+		status  int
+		code    string
+	}{
+		{name: "update with sha", method: "PUT", path: "README.md", body: `{"content": "x", "branch": "master", "sha": "{{README.md}}"}`, status: 200},
+		{name: "update with If-Match", method: "PUT", path: "README.md", body: `{"content": "x", "branch": "master"}`, ifMatch: `"{{README.md}}"`, status: 200},
+		{name: "update with If-Match *", method: "PUT", path: "README.md", body: `{"content": "x", "branch": "master"}`, ifMatch: "*", status: 200},
+		{name: "update without sha", method: "PUT", path: "README.md", body: `{"content": "x", "branch": "master"}`, status: 409, code: "sha_mismatch"},
+		{name: "update with stale sha", method: "PUT", path: "README.md", body: `{"content": "x", "branch": "master", "sha": "{{other.md}}"}`, status: 409, code: "sha_mismatch"},
+		{name: "update with stale If-Match", method: "PUT", path: "README.md", body: `{"content": "x", "branch": "master"}`, ifMatch: `"{{other.md}}"`, status: 412, code: "precondition_failed"},
+		{name: "create with If-Match *", method: "PUT", path: "new.md", body: `{"content": "x", "branch": "master"}`, ifMatch: "*", status: 412, code: "precondition_failed"},
+		{name: "create with sha", method: "PUT", path: "new.md", body: `{"content": "x", "branch": "master", "sha": "{{README.md}}"}`, status: 409, code: "sha_mismatch"},
+		{name: "delete with sha", method: "DELETE", path: "README.md", body: `{"branch": "master", "sha": "{{README.md}}"}`, status: 200},
+		{name: "delete with If-Match", method: "DELETE", path: "README.md", body: `{"branch": "master"}`, ifMatch: `W/"{{README.md}}"`, status: 200},
+		{name: "delete without sha", method: "DELETE", path: "README.md", body: `{"branch": "master"}`, status: 422, code: "unprocessable_entity"},
+		{name: "delete with stale sha", method: "DELETE", path: "README.md", body: `{"branch": "master", "sha": "{{other.md}}"}`, status: 409, code: "sha_mismatch"},
+		{name: "delete with stale If-Match", method: "DELETE", path: "README.md", body: `{"branch": "master"}`, ifMatch: `"{{other.md}}"`, status: 412, code: "precondition_failed"},
+		{name: "delete missing file", method: "DELETE", path: "missing.md", body: `{"branch": "master"}`, ifMatch: "*", status: 412, code: "precondition_failed"},
+	}
+
+	for _, test := range tests {
+		tr := newTestRepo(t, map[string]string{"README.md": "readme", "other.md": "other"})
+		before := tr.refSha(t, "refs/heads/master")
+		header := http.Header{}
+		if test.ifMatch != "" {
+			header.Set("If-Match", tr.expand(test.ifMatch))
+		}
+
+		handler := UpdateFile
+		if test.method == "DELETE" {
+			handler = DeleteFile
+		}
+		w := tr.serve(handler, test.method, "/files/"+test.path, tr.expand(test.body), header, pathParams(test.path))
+		if w.Code != test.status {
+			t.Errorf("%v: expected status %v, got %v: %v", test.name, test.status, w.Code, w.Body.String())
+		}
+		if test.code != "" {
+			if e := decodeError(t, w); e.Code != test.code {
+				t.Errorf("%v: expected code %v, got %v", test.name, test.code, e.Code)
+			}
+		}
+
+		after := tr.refSha(t, "refs/heads/master")
+		if test.status == 200 && after == before {
+			t.Errorf("%v: expected master to move", test.name)
+		}
+		if test.status != 200 && after != before {
+			t.Errorf("%v: expected master to stay at %v, got %v", test.name, before, after)
+		}
+		tr.Close()
+	}
+}
+
+func TestMatchesETag(t *testing.T) {
+	sha := "0123456789012345678901234567890123456789"
+	tests := []struct {
+		header   string
+		sha      string
+		expected bool
+	}{
+		{header: `"` + sha + `"`, sha: sha, expected: true},
+		{header: sha, sha: sha, expected: true},
+		{header: `W/"` + sha + `"`, sha: sha, expected: true},
+		{header: `"abc", "` + sha + `"`, sha: sha, expected: true},
+		{header: "*", sha: sha, expected: true},
+		{header: "*", sha: "", expected: false},
+		{header: `"abc"`, sha: sha, expected: false},
+		{header: `""`, sha: "", expected: false},
+	}
+
+	for _, test := range tests {
+		if matches := matchesETag(test.header, test.sha); matches != test.expected {
+			t.Errorf("matchesETag(%q, %q): expected %v, got %v", test.header, test.sha, test.expected, matches)
+		}
+	}
+}
@@ -2,42 +2,112 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/netlify/netlify-git-api/repo"
 	"golang.org/x/net/context"
 )
 
-// Error is an error with a message
+const (
+	defaultPerPage = 30
+	maxPerPage     = 100
+)
+
+var (
+	// ErrNotSupported is returned by a Resolver for operations it doesn't support
+	ErrNotSupported = errors.New("Not supported by this server")
+	// ErrOTPRequired is returned by a Resolver when a user with two factor
+	// authentication didn't send a one time password
+	ErrOTPRequired = errors.New("A one time password is required")
+)
+
+// Error is an error with a message, a machine readable code and optional details
 type Error struct {
-	Msg string `json:"msg"`
+	Code    string      `json:"code"`
+	Msg     string      `json:"msg"`
+	Details interface{} `json:"details,omitempty"`
 }
 
-// InternalServerError sends an error response with a 500 status code
-func InternalServerError(w http.ResponseWriter, msg string) {
-	sendJSON(w, 500, &Error{Msg: msg})
+// BadRequestError sends an error response with a 400 status code
+func BadRequestError(w http.ResponseWriter, msg string) {
+	sendError(w, 400, "bad_request", msg, nil)
+}
+
+// NotAuthorizedError sends an error response with a 401 status code
+func NotAuthorizedError(w http.ResponseWriter, msg string) {
+	sendError(w, 401, "unauthorized", msg, nil)
+}
+
+// ForbiddenError sends an error response with a 403 status code
+func ForbiddenError(w http.ResponseWriter, msg string) {
+	sendError(w, 403, "forbidden", msg, nil)
 }
 
 // NotFoundError sends an error response with a 404 status code
 func NotFoundError(w http.ResponseWriter, msg string) {
-	sendJSON(w, 404, &Error{Msg: msg})
+	sendError(w, 404, "not_found", msg, nil)
 }
 
-// NotAuthorizedError sends an error response with a 401 status code
-func NotAuthorizedError(w http.ResponseWriter, msg string) {
-	sendJSON(w, 401, &Error{Msg: msg})
+// ConflictError sends an error response with a 409 status code
+func ConflictError(w http.ResponseWriter, msg string) {
+	sendError(w, 409, "conflict", msg, nil)
+}
+
+// UnprocessableEntityError sends an error response with a 422 status code
+func UnprocessableEntityError(w http.ResponseWriter, msg string) {
+	sendError(w, 422, "unprocessable_entity", msg, nil)
+}
+
+// TooManyRequestsError sends an error response with a 429 status code and a
+// Retry-After header
+func TooManyRequestsError(w http.ResponseWriter, msg string, wait time.Duration) {
+	retryAfter(w, wait)
+	sendError(w, 429, "too_many_requests", msg, nil)
+}
+
+// InternalServerError sends an error response with a 500 status code
+func InternalServerError(w http.ResponseWriter, msg string) {
+	sendError(w, 500, "internal_error", msg, nil)
 }
 
 // HandleError will serve an error response reflecting the error type
 func HandleError(w http.ResponseWriter, err error) {
-	switch err.(type) {
+	switch err {
+	case ErrNotSupported:
+		sendError(w, 501, "not_supported", err.Error(), nil)
+		return
+	case ErrOTPRequired:
+		sendError(w, 401, "otp_required", err.Error(), nil)
+		return
+	}
+
+	switch e := err.(type) {
 	default:
 		InternalServerError(w, err.Error())
 	case *repo.NotFoundError:
 		NotFoundError(w, err.Error())
+	case *repo.ForbiddenError:
+		ForbiddenError(w, err.Error())
+	case *repo.ConflictError:
+		ConflictError(w, err.Error())
+	case *repo.MergeConflictError:
+		sendError(w, 409, "merge_conflict", err.Error(), map[string]interface{}{"conflicts": e.Conflicts})
+	case *repo.NonFastForwardError:
+		sendError(w, 422, "non_fast_forward", err.Error(), map[string]string{"ref": e.Ref, "current": e.Current, "sha": e.Sha})
+	case *repo.InvalidShaError:
+		sendError(w, 422, "invalid_sha", err.Error(), nil)
+	case *repo.InvalidModeError:
+		sendError(w, 422, "invalid_mode", err.Error(), nil)
 	}
 }
 
+func sendError(w http.ResponseWriter, status int, code, msg string, details interface{}) {
+	sendJSON(w, status, &Error{Code: code, Msg: msg, Details: details})
+}
+
 func sendJSON(w http.ResponseWriter, status int, obj interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -45,6 +115,14 @@ func sendJSON(w http.ResponseWriter, status int, obj interface{}) {
 	encoder.Encode(obj)
 }
 
+// intParam parses an integer query parameter, returning def if it's empty
+func intParam(value string, def int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	return strconv.Atoi(value)
+}
+
 // Note - this methods panics if there's no repo in the context
 // The context for all handler methods should always have a repo
 func getRepo(ctx context.Context) *repo.Repo {
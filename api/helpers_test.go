@@ -0,0 +1,68 @@
+package api
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/netlify/netlify-git-api/repo"
+)
+
+func TestHandleError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{err: errors.New("boom"), status: 500, code: "internal_error"},
+		{err: ErrNotSupported, status: 501, code: "not_supported"},
+		{err: ErrOTPRequired, status: 401, code: "otp_required"},
+		{err: &repo.NotFoundError{}, status: 404, code: "not_found"},
+		{err: &repo.ForbiddenError{}, status: 403, code: "forbidden"},
+		{err: &repo.ConflictError{}, status: 409, code: "conflict"},
+		{err: &repo.MergeConflictError{Conflicts: []*repo.MergeConflict{{Path: "README.md"}}}, status: 409, code: "merge_conflict"},
+		{err: &repo.NonFastForwardError{Ref: "refs/heads/master"}, status: 422, code: "non_fast_forward"},
+		{err: &repo.InvalidShaError{}, status: 422, code: "invalid_sha"},
+		{err: &repo.InvalidModeError{}, status: 422, code: "invalid_mode"},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		HandleError(w, test.err)
+		if w.Code != test.status {
+			t.Errorf("HandleError(%T): expected status %v, got %v", test.err, test.status, w.Code)
+		}
+		if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("HandleError(%T): expected a JSON response, got %v", test.err, contentType)
+		}
+		e := decodeError(t, w)
+		if e.Code != test.code || e.Msg != test.err.Error() {
+			t.Errorf("HandleError(%T): expected %v %q, got %v %q", test.err, test.code, test.err.Error(), e.Code, e.Msg)
+		}
+	}
+}
+
+func TestIntParam(t *testing.T) {
+	tests := []struct {
+		value    string
+		def      int
+		expected int
+		err      bool
+	}{
+		{value: "", def: 30, expected: 30},
+		{value: "5", def: 30, expected: 5},
+		{value: "-1", def: 30, expected: -1},
+		{value: "five", def: 30, err: true},
+	}
+
+	for _, test := range tests {
+		value, err := intParam(test.value, test.def)
+		if test.err != (err != nil) {
+			t.Errorf("intParam(%q): expected error %v, got %v", test.value, test.err, err)
+			continue
+		}
+		if !test.err && value != test.expected {
+			t.Errorf("intParam(%q): expected %v, got %v", test.value, test.expected, value)
+		}
+	}
+}
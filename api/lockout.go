@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/netlify/netlify-git-api/lockout"
+)
+
+// loginKeys are the lockout keys for a login attempt, one for the email and
+// one for the IP of the client
+func (a *API) loginKeys(r *http.Request, email string) []string {
+	return []string{lockout.EmailKey(email), lockout.IPKey(a.clientIP(r))}
+}
+
+// clientIP returns the IP of the client. With a ClientIPHeader the last
+// address in the header is used, which is the one the proxy added.
+func (a *API) clientIP(r *http.Request) string {
+	if a.config.ClientIPHeader != "" {
+		addresses := strings.Split(r.Header.Get(a.config.ClientIPHeader), ",")
+		if ip := strings.TrimSpace(addresses[len(addresses)-1]); ip != "" {
+			return ip
+		}
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// loginWait returns how long a client has to wait before the next login
+// attempt for an email
+func (a *API) loginWait(r *http.Request, email string) (time.Duration, error) {
+	if a.config.Lockout == nil {
+		return 0, nil
+	}
+	return a.config.Lockout.Check(a.loginKeys(r, email)...)
+}
+
+// recordLogin counts a failed login against the email and the client IP and
+// resets the failures of the email after a successful login
+func (a *API) recordLogin(r *http.Request, email string, ok bool) error {
+	if a.config.Lockout == nil {
+		return nil
+	}
+	keys := a.loginKeys(r, email)
+	if ok {
+		return a.config.Lockout.Reset(keys[0])
+	}
+	return a.config.Lockout.Fail(keys...)
+}
+
+// retryAfter sets the Retry-After header in whole seconds, rounded up
+func retryAfter(w http.ResponseWriter, wait time.Duration) {
+	seconds := int((wait + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/netlify/netlify-git-api/lockout"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		header     string
+		remoteAddr string
+		forwarded  string
+		expected   string
+	}{
+		{remoteAddr: "10.0.0.1:1234", expected: "10.0.0.1"},
+		{remoteAddr: "[::1]:1234", expected: "::1"},
+		{remoteAddr: "10.0.0.1", expected: "10.0.0.1"},
+		{remoteAddr: "10.0.0.1:1234", forwarded: "192.168.1.1", expected: "10.0.0.1"},
+		{header: "X-Forwarded-For", remoteAddr: "10.0.0.1:1234", forwarded: "192.168.1.1", expected: "192.168.1.1"},
+		{header: "X-Forwarded-For", remoteAddr: "10.0.0.1:1234", forwarded: "1.2.3.4, 192.168.1.1", expected: "192.168.1.1"},
+		{header: "X-Forwarded-For", remoteAddr: "10.0.0.1:1234", expected: "10.0.0.1"},
+		{header: "X-Real-IP", remoteAddr: "10.0.0.1:1234", forwarded: "192.168.1.1", expected: "10.0.0.1"},
+	}
+
+	for _, test := range tests {
+		a := &API{config: &Config{ClientIPHeader: test.header}}
+		req, _ := http.NewRequest("POST", "/token", nil)
+		req.RemoteAddr = test.remoteAddr
+		if test.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+		if ip := a.clientIP(req); ip != test.expected {
+			t.Errorf("clientIP(%q, %q, %q): expected %v, got %v", test.header, test.remoteAddr, test.forwarded, test.expected, ip)
+		}
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lockout-test")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := &API{
+		resolver: &testResolver{password: "secret"},
+		config: &Config{
+			AllowedOrigins: []string{"https://cms.example.com"},
+			Lockout: lockout.New(&lockout.Config{
+				Path:        filepath.Join(dir, "lockout.json"),
+				DelayAfter:  2,
+				MaxFailures: 10,
+				BaseDelay:   time.Minute,
+				MaxDelay:    time.Hour,
+				Duration:    time.Hour,
+			}),
+		},
+	}
+
+	tests := []struct {
+		password string
+		status   int
+	}{
+		{password: "wrong", status: 401},
+		{password: "wrong", status: 401},
+		{password: "wrong", status: 401},
+		{password: "secret", status: 429},
+	}
+
+	for i, test := range tests {
+		form := url.Values{"email": {"test@example.com"}, "password": {test.password}}
+		req, _ := http.NewRequest("POST", "/auth?origin=https://cms.example.com", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		a.Auth(w, req, nil)
+
+		if w.Code != test.status {
+			t.Errorf("Attempt %v: expected status %v, got %v", i+1, test.status, w.Code)
+		}
+		if test.status == 429 && w.Header().Get("Retry-After") != "60" {
+			t.Errorf("Attempt %v: expected Retry-After 60, got %q", i+1, w.Header().Get("Retry-After"))
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/net/context"
+)
+
+// MergeCreateParams is the JSON object sent when merging branches
+type MergeCreateParams struct {
+	Base    string `json:"base"`
+	Head    string `json:"head"`
+	Message string `json:"commit_message"`
+}
+
+// CreateMerge merges the `head` branch, tag or sha into the `base` branch.
+// Responds with the merge commit, with 204 if there was nothing to merge or
+// with 409 and the list of conflicting paths in the error details. Responds
+// with 409 as well if the base branch moves while merging.
+func CreateMerge(w http.ResponseWriter, r *http.Request, params httprouter.Params, ctx context.Context) {
+	currentRepo := getRepo(ctx)
+	mergeParams := &MergeCreateParams{}
+	jsonDecoder := json.NewDecoder(r.Body)
+	err := jsonDecoder.Decode(mergeParams)
+	if err != nil {
+		BadRequestError(w, fmt.Sprintf("Could not read merge params: %v", err))
+		return
+	}
+
+	commit, err := currentRepo.Merge(mergeParams.Base, mergeParams.Head, mergeParams.Message)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	if commit == nil {
+		w.WriteHeader(204)
+		return
+	}
+
+	sendJSON(w, 201, commit)
+}
@@ -5,17 +5,38 @@ import (
 	"fmt"
 	"net/http"
 	"path"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 	"golang.org/x/net/context"
 )
 
+// RefCreateParams is the JSON object sent when creating a ref
+type RefCreateParams struct {
+	Ref string `json:"ref"`
+	Sha string `json:"sha"`
+}
+
 // RefUpdateParams is the JSON object sent when patching a ref
 type RefUpdateParams struct {
 	Sha   string `json:"sha"`
 	Force bool   `json:"force"`
 }
 
+// ListRefs returns all references, optionally filtered by a `prefix` query
+// parameter (ie. heads/ or tags/)
+func ListRefs(w http.ResponseWriter, r *http.Request, params httprouter.Params, ctx context.Context) {
+	currentRepo := getRepo(ctx)
+	prefix := "refs/" + strings.TrimPrefix(r.URL.Query().Get("prefix"), "refs/")
+	refs, err := currentRepo.ListRefs(prefix)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	sendJSON(w, 200, refs)
+}
+
 // GetRef returns a specific reference
 func GetRef(w http.ResponseWriter, r *http.Request, params httprouter.Params, ctx context.Context) {
 	currentRepo := getRepo(ctx)
@@ -29,7 +50,36 @@ func GetRef(w http.ResponseWriter, r *http.Request, params httprouter.Params, ct
 	sendJSON(w, 200, ref)
 }
 
+// CreateRef creates a new reference pointing to a sha. The `ref` name may
+// leave out the refs/ prefix (ie. heads/feature).
+func CreateRef(w http.ResponseWriter, r *http.Request, params httprouter.Params, ctx context.Context) {
+	currentRepo := getRepo(ctx)
+	refParams := &RefCreateParams{}
+	jsonDecoder := json.NewDecoder(r.Body)
+	err := jsonDecoder.Decode(refParams)
+	if err != nil {
+		BadRequestError(w, fmt.Sprintf("Bad parameters to create: %v", err))
+		return
+	}
+
+	name := strings.Trim(strings.TrimPrefix(refParams.Ref, "refs/"), "/")
+	if name == "" || strings.Contains(name, "..") {
+		BadRequestError(w, fmt.Sprintf("Invalid ref name: %q", refParams.Ref))
+		return
+	}
+
+	refName := path.Join("refs", name)
+	ref, err := currentRepo.CreateRef(refName, refParams.Sha)
+	if err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	sendJSON(w, 201, ref)
+}
+
 // UpdateRef sets a new target for a reference
+// Only fast forward updates are allowed unless `force` is set
 func UpdateRef(w http.ResponseWriter, r *http.Request, params httprouter.Params, ctx context.Context) {
 	currentRepo := getRepo(ctx)
 	refName := path.Join("refs", params.ByName("ref"))
@@ -37,11 +87,11 @@ func UpdateRef(w http.ResponseWriter, r *http.Request, params httprouter.Params,
 	jsonDecoder := json.NewDecoder(r.Body)
 	err := jsonDecoder.Decode(refParams)
 	if err != nil {
-		InternalServerError(w, fmt.Sprintf("Bad parameters to update: %v", err))
+		BadRequestError(w, fmt.Sprintf("Bad parameters to update: %v", err))
 		return
 	}
 
-	ref, err := currentRepo.UpdateRef(refName, refParams.Sha)
+	ref, err := currentRepo.UpdateRef(refName, refParams.Sha, refParams.Force)
 	if err != nil {
 		HandleError(w, err)
 		return
@@ -49,3 +99,15 @@ func UpdateRef(w http.ResponseWriter, r *http.Request, params httprouter.Params,
 
 	sendJSON(w, 200, ref)
 }
+
+// DeleteRef removes a reference
+func DeleteRef(w http.ResponseWriter, r *http.Request, params httprouter.Params, ctx context.Context) {
+	currentRepo := getRepo(ctx)
+	refName := path.Join("refs", params.ByName("ref"))
+	if err := currentRepo.DeleteRef(refName); err != nil {
+		HandleError(w, err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestUpdateRef(t *testing.T) {
+	tr := newTestRepo(t, map[string]string{"README.md": "readme"})
+	defer tr.Close()
+
+	base := tr.refSha(t, "refs/heads/master")
+	ahead := tr.commit(t, base, map[string]string{"README.md": "ahead"}).Sha
+	params := httprouter.Params{{Key: "ref", Value: "/heads/feature"}}
+
+	tests := []struct {
+		start  string
+		body   string
+		status int
+		code   string
+		sha    string
+	}{
+		{start: base, body: `{"sha": "` + ahead + `"}`, status: 200, sha: ahead},
+		{start: ahead, body: `{"sha": "` + base + `"}`, status: 422, code: "non_fast_forward", sha: ahead},
+		{start: ahead, body: `{"sha": "` + base + `", "force": true}`, status: 200, sha: base},
+		{start: base, body: `{"sha": "nope"}`, status: 422, code: "invalid_sha", sha: base},
+		{start: base, body: `{"sha": `, status: 400, code: "bad_request", sha: base},
+	}
+
+	for _, test := range tests {
+		tr.setRef(t, "refs/heads/feature", test.start)
+		w := tr.serve(UpdateRef, "PATCH", "/refs/heads/feature", test.body, nil, params)
+		if w.Code != test.status {
+			t.Errorf("PATCH %v: expected status %v, got %v: %v", test.body, test.status, w.Code, w.Body.String())
+		}
+		if test.code != "" {
+			if e := decodeError(t, w); e.Code != test.code {
+				t.Errorf("PATCH %v: expected code %v, got %v", test.body, test.code, e.Code)
+			}
+		}
+		if sha := tr.refSha(t, "refs/heads/feature"); sha != test.sha {
+			t.Errorf("PATCH %v: expected the ref to point to %v, got %v", test.body, test.sha, sha)
+		}
+	}
+}
+
+func TestCreateRef(t *testing.T) {
+	tr := newTestRepo(t, map[string]string{"README.md": "readme"})
+	defer tr.Close()
+
+	master := tr.refSha(t, "refs/heads/master")
+
+	tests := []struct {
+		body   string
+		status int
+		code   string
+		ref    string
+	}{
+		{body: `{"ref": "heads/feature", "sha": "` + master + `"}`, status: 201, ref: "refs/heads/feature"},
+		{body: `{"ref": "refs/heads/other", "sha": "` + master + `"}`, status: 201, ref: "refs/heads/other"},
+		{body: `{"ref": "heads/master", "sha": "` + master + `"}`, status: 409, code: "conflict"},
+		{body: `{"ref": "", "sha": "` + master + `"}`, status: 400, code: "bad_request"},
+		{body: `{"ref": "refs/", "sha": "` + master + `"}`, status: 400, code: "bad_request"},
+		{body: `{"ref": "heads/../../config", "sha": "` + master + `"}`, status: 400, code: "bad_request"},
+		{body: `{"ref": `, status: 400, code: "bad_request"},
+	}
+
+	for _, test := range tests {
+		w := tr.serve(CreateRef, "POST", "/refs", test.body, nil, nil)
+		if w.Code != test.status {
+			t.Errorf("POST %v: expected status %v, got %v: %v", test.body, test.status, w.Code, w.Body.String())
+			continue
+		}
+		if test.code != "" {
+			if e := decodeError(t, w); e.Code != test.code {
+				t.Errorf("POST %v: expected code %v, got %v", test.body, test.code, e.Code)
+			}
+		}
+		if test.ref != "" && tr.refSha(t, test.ref) != master {
+			t.Errorf("POST %v: expected %v to point to %v", test.body, test.ref, master)
+		}
+	}
+}
@@ -23,13 +23,14 @@ func CreateTree(w http.ResponseWriter, r *http.Request, params httprouter.Params
 	jsonDecoder := json.NewDecoder(r.Body)
 	err := jsonDecoder.Decode(treeParams)
 	if err != nil {
-		InternalServerError(w, fmt.Sprintf("Could not read tree creation params: %v", err))
+		BadRequestError(w, fmt.Sprintf("Could not read tree creation params: %v", err))
 		return
 	}
 
 	tree, err := currentRepo.CreateTree(treeParams.Base, treeParams.Tree)
 	if err != nil {
 		HandleError(w, err)
+		return
 	}
 
 	sendJSON(w, 200, tree)
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/netlify/netlify-git-api/userdb"
+)
+
+// Authenticator checks user credentials against a user directory
+type Authenticator interface {
+	// Authenticate returns the user matching the credentials or nil
+	Authenticate(email, password string) (*userdb.User, error)
+	// Get returns a previously authenticated user by id or nil if the user
+	// no longer exists
+	Get(id string) *userdb.User
+}
+
+// UserDB authenticates against the bcrypt hashes in the user db
+type UserDB struct {
+	store userdb.Store
+}
+
+// NewUserDB creates an authenticator for the user db
+func NewUserDB(store userdb.Store) *UserDB {
+	return &UserDB{store: store}
+}
+
+// Authenticate returns the user matching the credentials or nil
+func (a *UserDB) Authenticate(email, password string) (*userdb.User, error) {
+	user, err := a.store.LookupByEmail(email)
+	if err != nil || user == nil || !user.Authenticate(password) {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Get returns a user by id, nil if the user db can't be read
+func (a *UserDB) Get(id string) *userdb.User {
+	user, err := a.store.Get(id)
+	if err != nil {
+		return nil
+	}
+	return user
+}
+
+// externalUsers maps users from an external directory to the user db.
+// Users that exist in the user db (by email) keep their roles and permissions,
+// others get the default roles and an id prefixed with the directory name.
+type externalUsers struct {
+	mutex        sync.Mutex
+	prefix       string
+	store        userdb.Store
+	defaultRoles []string
+	users        map[string]*userdb.User
+}
+
+func newExternalUsers(prefix string, store userdb.Store, defaultRoles []string) *externalUsers {
+	return &externalUsers{prefix: prefix + ":", store: store, defaultRoles: defaultRoles, users: map[string]*userdb.User{}}
+}
+
+func (e *externalUsers) user(email, name string) (*userdb.User, error) {
+	user, err := e.store.LookupByEmail(email)
+	if err != nil || user != nil {
+		return user, err
+	}
+
+	if name == "" {
+		name = email
+	}
+	user = &userdb.User{ID: e.prefix + email, Name: name, Email: email, Roles: e.defaultRoles}
+
+	e.mutex.Lock()
+	e.users[user.ID] = user
+	e.mutex.Unlock()
+
+	return user, nil
+}
+
+// get returns the user for an id and the email of external users
+func (e *externalUsers) get(id string) (*userdb.User, string) {
+	if !strings.HasPrefix(id, e.prefix) {
+		user, err := e.store.Get(id)
+		if err != nil {
+			return nil, ""
+		}
+		return user, ""
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	email := strings.TrimPrefix(id, e.prefix)
+	user, ok := e.users[id]
+	if !ok {
+		// Authenticated before a restart, the name is no longer known
+		user = &userdb.User{ID: id, Name: email, Email: email, Roles: e.defaultRoles}
+		e.users[id] = user
+	}
+	return user, email
+}
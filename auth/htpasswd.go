@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"os"
+	"strings"
+
+	"github.com/netlify/netlify-git-api/userdb"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Htpasswd authenticates against an Apache htpasswd file with bcrypt, apr1
+// (MD5) or SHA1 hashes. The usernames in the file are used as emails.
+type Htpasswd struct {
+	path  string
+	users *externalUsers
+}
+
+// NewHtpasswd creates an authenticator for the htpasswd file at path
+func NewHtpasswd(path string, store userdb.Store, defaultRoles []string) *Htpasswd {
+	return &Htpasswd{path: path, users: newExternalUsers("htpasswd", store, defaultRoles)}
+}
+
+// Authenticate returns the user matching the credentials or nil
+func (a *Htpasswd) Authenticate(email, password string) (*userdb.User, error) {
+	hashes, err := a.read()
+	if err != nil {
+		return nil, err
+	}
+
+	hash, ok := hashes[email]
+	if !ok || !checkHtpasswd(hash, password) {
+		return nil, nil
+	}
+	return a.users.user(email, "")
+}
+
+// Get returns a user by id if they are still in the htpasswd file
+func (a *Htpasswd) Get(id string) *userdb.User {
+	user, email := a.users.get(id)
+	if email == "" {
+		return user
+	}
+
+	hashes, err := a.read()
+	if err != nil {
+		return nil
+	}
+	if _, ok := hashes[email]; !ok {
+		return nil
+	}
+	return user
+}
+
+// read parses the htpasswd file into a map of usernames to hashes. The file is
+// read on every call so changes take effect without a restart.
+func (a *Htpasswd) read() (map[string]string, error) {
+	file, err := os.Open(a.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hashes := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) == 2 {
+			hashes[parts[0]] = parts[1]
+		}
+	}
+	return hashes, scanner.Err()
+}
+
+func checkHtpasswd(hash, password string) bool {
+	switch {
+	case strings.HasPrefix(hash, "$2y$"), strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"):
+		// $2y$ and $2b$ are the same algorithm as $2a$
+		hash = "$2a$" + hash[4:]
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case strings.HasPrefix(hash, "$apr1$"):
+		parts := strings.SplitN(hash, "$", 4)
+		if len(parts) != 4 {
+			return false
+		}
+		return secureCompare(apr1(password, parts[2]), hash)
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		return secureCompare("{SHA}"+base64.StdEncoding.EncodeToString(sum[:]), hash)
+	default:
+		// crypt(3) and plain text passwords are not supported
+		return false
+	}
+}
+
+func secureCompare(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+const itoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// apr1 is the Apache variant of the MD5 crypt algorithm
+func apr1(password, salt string) string {
+	pw := []byte(password)
+	sb := []byte(salt)
+
+	alt := md5.New()
+	alt.Write(pw)
+	alt.Write(sb)
+	alt.Write(pw)
+	altSum := alt.Sum(nil)
+
+	ctx := md5.New()
+	ctx.Write(pw)
+	ctx.Write([]byte("$apr1$"))
+	ctx.Write(sb)
+	for i := len(pw); i > 0; i -= 16 {
+		if i > 16 {
+			ctx.Write(altSum)
+		} else {
+			ctx.Write(altSum[:i])
+		}
+	}
+	for i := len(pw); i > 0; i >>= 1 {
+		if i&1 == 1 {
+			ctx.Write([]byte{0})
+		} else {
+			ctx.Write(pw[:1])
+		}
+	}
+	final := ctx.Sum(nil)
+
+	for i := 0; i < 1000; i++ {
+		round := md5.New()
+		if i&1 == 1 {
+			round.Write(pw)
+		} else {
+			round.Write(final)
+		}
+		if i%3 != 0 {
+			round.Write(sb)
+		}
+		if i%7 != 0 {
+			round.Write(pw)
+		}
+		if i&1 == 1 {
+			round.Write(final)
+		} else {
+			round.Write(pw)
+		}
+		final = round.Sum(nil)
+	}
+
+	result := []byte{}
+	encode := func(v uint, n int) {
+		for ; n > 0; n-- {
+			result = append(result, itoa64[v&0x3f])
+			v >>= 6
+		}
+	}
+	encode(uint(final[0])<<16|uint(final[6])<<8|uint(final[12]), 4)
+	encode(uint(final[1])<<16|uint(final[7])<<8|uint(final[13]), 4)
+	encode(uint(final[2])<<16|uint(final[8])<<8|uint(final[14]), 4)
+	encode(uint(final[3])<<16|uint(final[9])<<8|uint(final[15]), 4)
+	encode(uint(final[4])<<16|uint(final[10])<<8|uint(final[5]), 4)
+	encode(uint(final[11]), 2)
+
+	return "$apr1$" + salt + "$" + string(result)
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/netlify/netlify-git-api/userdb"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckHtpasswd(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error hashing password: %v", err)
+	}
+	bcryptHash := string(hash)
+
+	tests := []struct {
+		hash     string
+		password string
+		expected bool
+	}{
+		{hash: "$apr1$r31....$kMmt8Ia8qcWk4vKKEhpgx1", password: "password", expected: true},
+		{hash: "$apr1$r31....$kMmt8Ia8qcWk4vKKEhpgx1", password: "Password", expected: false},
+		{hash: "$apr1$saltsalt$qc5101oD7TfGcDyRin53E.", password: "a longer password with more than 16 chars", expected: true},
+		{hash: "$apr1$broken", password: "password", expected: false},
+		{hash: bcryptHash, password: "password", expected: true},
+		{hash: bcryptHash, password: "wrong", expected: false},
+		{hash: "$2y$" + bcryptHash[4:], password: "password", expected: true},
+		{hash: "$2b$" + bcryptHash[4:], password: "password", expected: true},
+		{hash: "{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=", password: "password", expected: true},
+		{hash: "{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=", password: "wrong", expected: false},
+		{hash: "password", password: "password", expected: false},
+		{hash: "rqXexS6ZhobKA", password: "password", expected: false},
+	}
+
+	for _, test := range tests {
+		if ok := checkHtpasswd(test.hash, test.password); ok != test.expected {
+			t.Errorf("checkHtpasswd(%q, %q): expected %v, got %v", test.hash, test.password, test.expected, ok)
+		}
+	}
+}
+
+func TestHtpasswd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "htpasswd-test")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, ".htpasswd")
+	contents := "# users\nalice@example.com:$apr1$r31....$kMmt8Ia8qcWk4vKKEhpgx1\n\nbob@example.com:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\n"
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("Error writing htpasswd file: %v", err)
+	}
+
+	db := &userdb.UserDB{Users: []userdb.User{{ID: "bob-id", Email: "bob@example.com", Roles: []string{"editor"}}}}
+	a := NewHtpasswd(path, userdb.NewMemoryStore(db), []string{"contributor"})
+
+	tests := []struct {
+		email    string
+		password string
+		id       string
+	}{
+		{email: "alice@example.com", password: "password", id: "htpasswd:alice@example.com"},
+		{email: "alice@example.com", password: "wrong"},
+		{email: "bob@example.com", password: "password", id: "bob-id"},
+		{email: "# users", password: ""},
+		{email: "nobody@example.com", password: "password"},
+	}
+
+	for _, test := range tests {
+		user, err := a.Authenticate(test.email, test.password)
+		if err != nil {
+			t.Errorf("Authenticate(%q): unexpected error %v", test.email, err)
+			continue
+		}
+		if test.id == "" {
+			if user != nil {
+				t.Errorf("Authenticate(%q, %q): expected no user, got %v", test.email, test.password, user.ID)
+			}
+			continue
+		}
+		if user == nil || user.ID != test.id {
+			t.Errorf("Authenticate(%q, %q): expected user %v, got %v", test.email, test.password, test.id, user)
+		}
+	}
+
+	if user := a.Get("htpasswd:alice@example.com"); user == nil || user.Roles[0] != "contributor" {
+		t.Errorf("Expected alice with the default roles, got %v", user)
+	}
+
+	// Removing a user from the file takes effect without a restart
+	if err := ioutil.WriteFile(path, []byte("bob@example.com:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\n"), 0600); err != nil {
+		t.Fatalf("Error writing htpasswd file: %v", err)
+	}
+	if user := a.Get("htpasswd:alice@example.com"); user != nil {
+		t.Errorf("Expected alice to be gone, got %v", user)
+	}
+	if user, _ := a.Authenticate("alice@example.com", "password"); user != nil {
+		t.Errorf("Expected alice not to authenticate, got %v", user)
+	}
+	if user := a.Get("bob-id"); user == nil {
+		t.Errorf("Expected bob from the user db")
+	}
+}
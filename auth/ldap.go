@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/netlify/netlify-git-api/userdb"
+	"gopkg.in/ldap.v2"
+)
+
+// LDAPConfig configures the LDAP directory to bind against. The user is
+// searched with Filter (where %s is the escaped email) below BaseDN, using
+// BindDN and BindPassword if the directory doesn't allow anonymous search.
+// Users with a token are checked against the directory again once their last
+// check is older than CacheTTL (1 minute by default).
+type LDAPConfig struct {
+	Addr               string        `yaml:"addr"`
+	TLS                bool          `yaml:"tls"`
+	StartTLS           bool          `yaml:"start_tls"`
+	InsecureSkipVerify bool          `yaml:"insecure_skip_verify"`
+	BaseDN             string        `yaml:"base_dn"`
+	BindDN             string        `yaml:"bind_dn"`
+	BindPassword       string        `yaml:"bind_password"`
+	Filter             string        `yaml:"filter"`
+	NameAttribute      string        `yaml:"name_attribute"`
+	EmailAttribute     string        `yaml:"email_attribute"`
+	CacheTTL           time.Duration `yaml:"cache_ttl"`
+}
+
+// LDAP authenticates by binding to an LDAP directory as the user
+type LDAP struct {
+	config *LDAPConfig
+	users  *externalUsers
+
+	mutex   sync.Mutex
+	checked map[string]time.Time
+}
+
+// NewLDAP creates an authenticator for an LDAP directory
+func NewLDAP(config *LDAPConfig, store userdb.Store, defaultRoles []string) *LDAP {
+	if config.Filter == "" {
+		config.Filter = "(&(objectClass=person)(mail=%s))"
+	}
+	if config.NameAttribute == "" {
+		config.NameAttribute = "cn"
+	}
+	if config.EmailAttribute == "" {
+		config.EmailAttribute = "mail"
+	}
+	if config.CacheTTL == 0 {
+		config.CacheTTL = time.Minute
+	}
+	return &LDAP{config: config, users: newExternalUsers("ldap", store, defaultRoles), checked: map[string]time.Time{}}
+}
+
+// Authenticate returns the user matching the credentials or nil
+func (a *LDAP) Authenticate(email, password string) (*userdb.User, error) {
+	// An empty password would be an unauthenticated bind that always succeeds
+	if email == "" || password == "" {
+		return nil, nil
+	}
+
+	conn, err := a.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entry, err := a.search(conn, email)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		return nil, nil
+	}
+
+	if mail := entry.GetAttributeValue(a.config.EmailAttribute); mail != "" {
+		email = mail
+	}
+	user, err := a.users.user(email, entry.GetAttributeValue(a.config.NameAttribute))
+	if err != nil {
+		return nil, err
+	}
+	a.setChecked(email, true)
+	return user, nil
+}
+
+// Get returns a previously authenticated user by id if they are still in the
+// directory. The directory is only searched again after CacheTTL.
+func (a *LDAP) Get(id string) *userdb.User {
+	user, email := a.users.get(id)
+	if email == "" {
+		return user
+	}
+
+	a.mutex.Lock()
+	checkedAt, ok := a.checked[email]
+	a.mutex.Unlock()
+	if ok && time.Since(checkedAt) < a.config.CacheTTL {
+		return user
+	}
+
+	exists, err := a.exists(email)
+	if err != nil {
+		return nil
+	}
+	a.setChecked(email, exists)
+	if !exists {
+		return nil
+	}
+	return user
+}
+
+// exists checks if there is a user with the email in the directory
+func (a *LDAP) exists(email string) (bool, error) {
+	conn, err := a.dial()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	entry, err := a.search(conn, email)
+	return entry != nil, err
+}
+
+func (a *LDAP) setChecked(email string, exists bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if exists {
+		a.checked[email] = time.Now()
+	} else {
+		delete(a.checked, email)
+	}
+}
+
+// search looks up the entry for an email, binding with the BindDN first if
+// set. Returns nil unless there is exactly one match.
+func (a *LDAP) search(conn *ldap.Conn, email string) (*ldap.Entry, error) {
+	if a.config.BindDN != "" {
+		if err := conn.Bind(a.config.BindDN, a.config.BindPassword); err != nil {
+			return nil, fmt.Errorf("Error binding to LDAP as %v: %v", a.config.BindDN, err)
+		}
+	}
+
+	search := ldap.NewSearchRequest(
+		a.config.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		fmt.Sprintf(a.config.Filter, ldap.EscapeFilter(email)),
+		[]string{"dn", a.config.NameAttribute, a.config.EmailAttribute},
+		nil,
+	)
+	result, err := conn.Search(search)
+	if err != nil {
+		return nil, fmt.Errorf("Error searching LDAP for %v: %v", email, err)
+	}
+	if len(result.Entries) != 1 {
+		return nil, nil
+	}
+	return result.Entries[0], nil
+}
+
+func (a *LDAP) dial() (*ldap.Conn, error) {
+	host, _, err := net.SplitHostPort(a.config.Addr)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{ServerName: host, InsecureSkipVerify: a.config.InsecureSkipVerify}
+	if a.config.TLS {
+		return ldap.DialTLS("tcp", a.config.Addr, tlsConfig)
+	}
+
+	conn, err := ldap.Dial("tcp", a.config.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if a.config.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
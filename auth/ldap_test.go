@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/netlify/netlify-git-api/userdb"
+	"gopkg.in/asn1-ber.v1"
+	"gopkg.in/ldap.v2"
+)
+
+type fakeLDAPEntry struct {
+	name     string
+	email    string
+	password string
+}
+
+// fakeLDAP is a minimal in-process LDAP server that answers simple binds and
+// searches for entries by their mail attribute
+type fakeLDAP struct {
+	listener net.Listener
+	bindDN   string
+	bindPW   string
+
+	mutex   sync.Mutex
+	entries map[string]*fakeLDAPEntry
+	binds   int
+}
+
+func newFakeLDAP(t *testing.T) *fakeLDAP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %v", err)
+	}
+	server := &fakeLDAP{
+		listener: listener,
+		bindDN:   "cn=search,dc=example,dc=com",
+		bindPW:   "search",
+		entries:  map[string]*fakeLDAPEntry{},
+	}
+	go server.serve()
+	return server
+}
+
+func (s *fakeLDAP) Close() {
+	s.listener.Close()
+}
+
+func (s *fakeLDAP) add(dn, name, email, password string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.entries[dn] = &fakeLDAPEntry{name: name, email: email, password: password}
+}
+
+func (s *fakeLDAP) remove(dn string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.entries, dn)
+}
+
+func (s *fakeLDAP) bindCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.binds
+}
+
+func (s *fakeLDAP) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeLDAP) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		id := packet.Children[0].Value.(int64)
+		request := packet.Children[1]
+
+		switch request.Tag {
+		case ldap.ApplicationBindRequest:
+			dn, _ := request.Children[1].Value.(string)
+			code := ldap.LDAPResultInvalidCredentials
+			if s.bind(dn, request.Children[2].Data.String()) {
+				code = ldap.LDAPResultSuccess
+			}
+			conn.Write(fakeLDAPResult(id, ldap.ApplicationBindResponse, code).Bytes())
+		case ldap.ApplicationSearchRequest:
+			filter, _ := ldap.DecompileFilter(request.Children[6])
+			for _, response := range s.search(id, filter) {
+				conn.Write(response.Bytes())
+			}
+			conn.Write(fakeLDAPResult(id, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess).Bytes())
+		default:
+			return
+		}
+	}
+}
+
+func (s *fakeLDAP) bind(dn, password string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.binds++
+
+	if dn == s.bindDN {
+		return password == s.bindPW
+	}
+	entry, ok := s.entries[dn]
+	return ok && password != "" && password == entry.password
+}
+
+func (s *fakeLDAP) search(id int64, filter string) []*ber.Packet {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	responses := []*ber.Packet{}
+	for dn, entry := range s.entries {
+		if !strings.Contains(filter, "(mail="+entry.email+")") {
+			continue
+		}
+		result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+		result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "DN"))
+		attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+		attributes.AppendChild(fakeLDAPAttribute("cn", entry.name))
+		attributes.AppendChild(fakeLDAPAttribute("mail", entry.email))
+		result.AppendChild(attributes)
+		responses = append(responses, fakeLDAPMessage(id, result))
+	}
+	return responses
+}
+
+func fakeLDAPMessage(id int64, op *ber.Packet) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+	packet.AppendChild(op)
+	return packet
+}
+
+func fakeLDAPResult(id int64, tag ber.Tag, code int) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "Result Code"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return fakeLDAPMessage(id, result)
+}
+
+func fakeLDAPAttribute(name, value string) *ber.Packet {
+	attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+	attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+	values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+	values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+	attribute.AppendChild(values)
+	return attribute
+}
+
+func newTestLDAP(server *fakeLDAP, db *userdb.UserDB) *LDAP {
+	return NewLDAP(&LDAPConfig{
+		Addr:         server.listener.Addr().String(),
+		BaseDN:       "dc=example,dc=com",
+		BindDN:       server.bindDN,
+		BindPassword: server.bindPW,
+	}, userdb.NewMemoryStore(db), []string{"contributor"})
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	server := newFakeLDAP(t)
+	defer server.Close()
+	server.add("uid=alice,dc=example,dc=com", "Alice", "alice@example.com", "secret")
+	server.add("uid=bob,dc=example,dc=com", "Bob", "bob@example.com", "hunter2")
+
+	db := &userdb.UserDB{Users: []userdb.User{{ID: "bob-id", Email: "bob@example.com", Roles: []string{"editor"}}}}
+	a := newTestLDAP(server, db)
+
+	tests := []struct {
+		email    string
+		password string
+		id       string
+		name     string
+		roles    []string
+	}{
+		{email: "alice@example.com", password: "secret", id: "ldap:alice@example.com", name: "Alice", roles: []string{"contributor"}},
+		{email: "alice@example.com", password: "wrong"},
+		{email: "alice@example.com", password: ""},
+		{email: "nobody@example.com", password: "secret"},
+		{email: "*", password: "secret"},
+		{email: "bob@example.com", password: "hunter2", id: "bob-id", roles: []string{"editor"}},
+	}
+
+	for _, test := range tests {
+		user, err := a.Authenticate(test.email, test.password)
+		if err != nil {
+			t.Errorf("Authenticate(%q, %q): unexpected error %v", test.email, test.password, err)
+			continue
+		}
+		if test.id == "" {
+			if user != nil {
+				t.Errorf("Authenticate(%q, %q): expected no user, got %v", test.email, test.password, user.ID)
+			}
+			continue
+		}
+		if user == nil || user.ID != test.id {
+			t.Errorf("Authenticate(%q, %q): expected user %v, got %v", test.email, test.password, test.id, user)
+			continue
+		}
+		if test.name != "" && user.Name != test.name {
+			t.Errorf("Authenticate(%q, %q): expected name %v, got %v", test.email, test.password, test.name, user.Name)
+		}
+		if strings.Join(user.Roles, ",") != strings.Join(test.roles, ",") {
+			t.Errorf("Authenticate(%q, %q): expected roles %v, got %v", test.email, test.password, test.roles, user.Roles)
+		}
+	}
+}
+
+func TestLDAPGetRevalidates(t *testing.T) {
+	server := newFakeLDAP(t)
+	defer server.Close()
+	server.add("uid=alice,dc=example,dc=com", "Alice", "alice@example.com", "secret")
+
+	a := newTestLDAP(server, &userdb.UserDB{})
+	user, err := a.Authenticate("alice@example.com", "secret")
+	if err != nil || user == nil {
+		t.Fatalf("Expected alice to authenticate, got %v, %v", user, err)
+	}
+
+	binds := server.bindCount()
+	if got := a.Get(user.ID); got == nil {
+		t.Fatalf("Expected alice to be found")
+	}
+	if server.bindCount() != binds {
+		t.Errorf("Expected a recently checked user to be served from the cache")
+	}
+
+	server.remove("uid=alice,dc=example,dc=com")
+	if got := a.Get(user.ID); got == nil {
+		t.Errorf("Expected alice to be cached until the cache ttl passes")
+	}
+
+	a.mutex.Lock()
+	a.checked["alice@example.com"] = time.Now().Add(-2 * a.config.CacheTTL)
+	a.mutex.Unlock()
+	if got := a.Get(user.ID); got != nil {
+		t.Errorf("Expected alice to be rejected after being removed from the directory")
+	}
+	if got := a.Get("ldap:unknown@example.com"); got != nil {
+		t.Errorf("Expected an unknown user to be rejected")
+	}
+
+	server.add("uid=alice,dc=example,dc=com", "Alice", "alice@example.com", "secret")
+	if got := a.Get(user.ID); got == nil || got.Email != "alice@example.com" {
+		t.Errorf("Expected alice to be found again, got %v", got)
+	}
+}
+
+func TestLDAPGetDirectoryDown(t *testing.T) {
+	server := newFakeLDAP(t)
+	server.add("uid=alice,dc=example,dc=com", "Alice", "alice@example.com", "secret")
+
+	a := newTestLDAP(server, &userdb.UserDB{})
+	user, err := a.Authenticate("alice@example.com", "secret")
+	if err != nil || user == nil {
+		t.Fatalf("Expected alice to authenticate, got %v, %v", user, err)
+	}
+
+	server.Close()
+	a.mutex.Lock()
+	a.checked["alice@example.com"] = time.Now().Add(-2 * a.config.CacheTTL)
+	a.mutex.Unlock()
+	if got := a.Get(user.ID); got != nil {
+		t.Errorf("Expected users not to be found while the directory is unreachable")
+	}
+}
@@ -4,27 +4,102 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/netlify/netlify-git-api/userdb"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 var (
 	app    = kingpin.New("netlify-git-api", "Get a REST API for a Git repository")
-	dbPath = app.Flag("db", "File path to the user db").Default(".users.yml").String()
+	dbPath = app.Flag("db", "File path to the user db (.yml, .json or .db for SQLite)").Default(".users.yml").String()
 
-	serve = app.Command("serve", "Start a local Git API server")
-	port  = serve.Flag("port", "Port to listen to").Short('p').Default("8080").String()
-	host  = serve.Flag("host", "IP to bind to").Short('h').Default("127.0.0.1").IP()
+	serve       = app.Command("serve", "Start a local Git API server")
+	port        = serve.Flag("port", "Port to listen to").Short('p').Default("8080").String()
+	host        = serve.Flag("host", "IP to bind to").Short('h').Default("127.0.0.1").IP()
+	readOnly    = serve.Flag("read-only", "Refuse all requests that write to the repository").Bool()
+	serveConfig = serve.Flag("config", "File path to the server config").Default(".netlify-git-api.yml").String()
+	tokenMode   = serve.Flag("token-mode", "Issue tokens kept in the token store or stateless signed JWTs").Default("store").Enum("store", "jwt")
+	authBackend = serve.Flag("auth-backend", "Authenticate against the user db, an htpasswd file or LDAP").Enum("userdb", "htpasswd", "ldap")
+	htpasswd    = serve.Flag("htpasswd", "File path to the htpasswd file for the htpasswd backend").String()
 	//sync  = serve.Flag("sync", "Push and pull to the origin remote ()").Short('s').Bool()
 
 	users = app.Command("users", "List users")
 
-	usersList        = users.Command("list", "List all users")
-	usersAdd         = users.Command("add", "Add a new user")
-	usersAddName     = usersAdd.Flag("name", "Name of the new user").String()
-	usersAddEmail    = usersAdd.Flag("email", "Email of new user").String()
-	usersAddPassword = usersAdd.Flag("password", "Password of new user").String()
-	usersDel         = users.Command("del", "Remove a user")
-	usersDelEmail    = usersDel.Arg("email", "Email of the user").String()
+	usersList            = users.Command("list", "List all users")
+	usersListJSON        = usersList.Flag("json", "Print the users as JSON").Bool()
+	usersShow            = users.Command("show", "Show the details of a user")
+	usersShowEmail       = usersShow.Arg("email", "Email of the user").Required().String()
+	usersShowJSON        = usersShow.Flag("json", "Print the user as JSON").Bool()
+	usersAdd             = users.Command("add", "Add a new user")
+	usersAddName         = usersAdd.Flag("name", "Name of the new user").String()
+	usersAddEmail        = usersAdd.Flag("email", "Email of new user").String()
+	usersAddPassword     = usersAdd.Flag("password", "Password of new user").String()
+	usersDel             = users.Command("del", "Remove a user")
+	usersDelEmail        = usersDel.Arg("email", "Email of the user").String()
+	usersPasswd          = users.Command("passwd", "Change the password of a user")
+	usersPasswdEmail     = usersPasswd.Arg("email", "Email of the user").Required().String()
+	usersPasswdPassword  = usersPasswd.Flag("password", "New password of the user").String()
+	usersUpdate          = users.Command("update", "Change the name or email of a user")
+	usersUpdateEmail     = usersUpdate.Arg("email", "Email of the user").Required().String()
+	usersUpdateName      = usersUpdate.Flag("name", "New name of the user").String()
+	usersUpdateNewEmail  = usersUpdate.Flag("email", "New email of the user").String()
+	usersDisable         = users.Command("disable", "Disable a user, revoking their access")
+	usersDisableEmail    = usersDisable.Arg("email", "Email of the user").Required().String()
+	usersEnable          = users.Command("enable", "Enable a disabled user")
+	usersEnableEmail     = usersEnable.Arg("email", "Email of the user").Required().String()
+	usersGrant           = users.Command("grant", "Grant a role to a user")
+	usersGrantEmail      = usersGrant.Arg("email", "Email of the user").Required().String()
+	usersGrantRole       = usersGrant.Arg("role", "Name of the role").Required().String()
+	usersGrantGroup      = usersGrant.Flag("group", "Add the user to a group instead of a role").Bool()
+	usersRevoke          = users.Command("revoke", "Revoke a role from a user")
+	usersRevokeEmail     = usersRevoke.Arg("email", "Email of the user").Required().String()
+	usersRevokeRole      = usersRevoke.Arg("role", "Name of the role").Required().String()
+	usersRevokeGroup     = usersRevoke.Flag("group", "Remove the user from a group instead of a role").Bool()
+	users2fa             = users.Command("2fa", "Manage two factor authentication")
+	users2faEnable       = users2fa.Command("enable", "Enable two factor authentication and print the otpauth URI")
+	users2faEnableEmail  = users2faEnable.Arg("email", "Email of the user").Required().String()
+	users2faDisable      = users2fa.Command("disable", "Disable two factor authentication")
+	users2faDisableEmail = users2faDisable.Arg("email", "Email of the user").Required().String()
+	usersMigrate         = users.Command("migrate", "Copy all users, roles and groups to another user db")
+	usersMigrateTo       = usersMigrate.Flag("to", "File path to the new user db (.yml, .json or .db for SQLite)").Required().String()
+	usersLocked          = users.Command("locked", "List users and IPs locked out after failed logins")
+	usersLockedConfig    = usersLocked.Flag("config", "File path to the server config").Default(".netlify-git-api.yml").String()
+	usersUnlock          = users.Command("unlock", "Clear the failed logins of a user")
+	usersUnlockEmail     = usersUnlock.Arg("email", "Email of the user").String()
+	usersUnlockIP        = usersUnlock.Flag("ip", "IP to unlock").String()
+	usersUnlockConfig    = usersUnlock.Flag("config", "File path to the server config").Default(".netlify-git-api.yml").String()
+
+	keys               = app.Command("keys", "Manage API keys")
+	keysCreate         = keys.Command("create", "Create an API key for a user")
+	keysCreateEmail    = keysCreate.Arg("email", "Email of the user").Required().String()
+	keysCreateName     = keysCreate.Arg("name", "Name of the key").Required().String()
+	keysCreateWrite    = keysCreate.Flag("write", "Allow the key to write to the repository").Bool()
+	keysCreatePaths    = keysCreate.Flag("path", "Pattern of paths the key may change (ie. content/**), repeatable").Strings()
+	keysCreateBranches = keysCreate.Flag("branch", "Pattern of branches the key may update (ie. cms/*), repeatable").Strings()
+	keysCreateExpires  = keysCreate.Flag("expires", "Time until the key expires (ie. 720h), never by default").Duration()
+	keysList           = keys.Command("list", "List API keys")
+	keysListEmail      = keysList.Arg("email", "Only list the keys of this user").String()
+	keysRevoke         = keys.Command("revoke", "Revoke an API key")
+	keysRevokeEmail    = keysRevoke.Arg("email", "Email of the user").Required().String()
+	keysRevokeName     = keysRevoke.Arg("name", "Name of the key").Required().String()
+
+	roles         = app.Command("roles", "Manage roles")
+	rolesList     = roles.Command("list", "List all roles")
+	rolesAdd      = roles.Command("add", "Add or replace a role")
+	rolesAddName  = rolesAdd.Arg("name", "Name of the role").Required().String()
+	rolesAddAllow = rolesAdd.Flag("allow", "Allow rule as action:pattern (ie. update:content/**), repeatable").Strings()
+	rolesAddDeny  = rolesAdd.Flag("deny", "Deny rule as action:pattern (ie. *:config.yml), repeatable").Strings()
+	rolesDel      = roles.Command("del", "Remove a role")
+	rolesDelName  = rolesDel.Arg("name", "Name of the role").Required().String()
+
+	groups         = app.Command("groups", "Manage groups")
+	groupsList     = groups.Command("list", "List all groups")
+	groupsAdd      = groups.Command("add", "Add or replace a group")
+	groupsAddName  = groupsAdd.Arg("name", "Name of the group").Required().String()
+	groupsAddAllow = groupsAdd.Flag("allow", "Allow rule as action:pattern (ie. update:content/**), repeatable").Strings()
+	groupsAddDeny  = groupsAdd.Flag("deny", "Deny rule as action:pattern (ie. *:config.yml), repeatable").Strings()
+	groupsAddRole  = groupsAdd.Flag("role", "Role the members of the group get, repeatable").Strings()
+	groupsDel      = groups.Command("del", "Remove a group and all users from it")
+	groupsDelName  = groupsDel.Arg("name", "Name of the group").Required().String()
 )
 
 // Run the cli tool
@@ -32,12 +107,66 @@ func Run() {
 	switch kingpin.MustParse(app.Parse(os.Args[1:])) {
 	case serve.FullCommand():
 		fmt.Printf("Starting server on %v:%v\n", *host, *port)
-		Serve(*dbPath, host.String(), *port, false)
+		Serve(*dbPath, &ServeOptions{
+			ConfigPath:  *serveConfig,
+			Host:        host.String(),
+			Port:        *port,
+			TokenMode:   *tokenMode,
+			AuthBackend: *authBackend,
+			Htpasswd:    *htpasswd,
+			ReadOnly:    *readOnly,
+		})
 	case usersList.FullCommand():
-		ListUsers(*dbPath)
+		ListUsers(*dbPath, *usersListJSON)
+	case usersShow.FullCommand():
+		ShowUser(*dbPath, *usersShowEmail, *usersShowJSON)
 	case usersAdd.FullCommand():
 		AddUser(*dbPath, *usersAddEmail, *usersAddName, *usersAddPassword)
 	case usersDel.FullCommand():
 		DeleteUser(*dbPath, *usersDelEmail)
+	case usersPasswd.FullCommand():
+		SetPassword(*dbPath, *usersPasswdEmail, *usersPasswdPassword)
+	case usersUpdate.FullCommand():
+		UpdateUser(*dbPath, *usersUpdateEmail, *usersUpdateName, *usersUpdateNewEmail)
+	case usersDisable.FullCommand():
+		SetDisabled(*dbPath, *usersDisableEmail, true)
+	case usersEnable.FullCommand():
+		SetDisabled(*dbPath, *usersEnableEmail, false)
+	case usersGrant.FullCommand():
+		GrantRole(*dbPath, *usersGrantEmail, *usersGrantRole, *usersGrantGroup)
+	case usersRevoke.FullCommand():
+		RevokeRole(*dbPath, *usersRevokeEmail, *usersRevokeRole, *usersRevokeGroup)
+	case users2faEnable.FullCommand():
+		EnableTOTP(*dbPath, *users2faEnableEmail)
+	case users2faDisable.FullCommand():
+		DisableTOTP(*dbPath, *users2faDisableEmail)
+	case usersMigrate.FullCommand():
+		MigrateUsers(*dbPath, *usersMigrateTo)
+	case usersLocked.FullCommand():
+		ListLocked(*usersLockedConfig)
+	case usersUnlock.FullCommand():
+		UnlockUser(*usersUnlockConfig, *usersUnlockEmail, *usersUnlockIP)
+	case keysCreate.FullCommand():
+		CreateKey(*dbPath, *keysCreateEmail, *keysCreateName, userdb.KeyScope{
+			Write:    *keysCreateWrite,
+			Paths:    *keysCreatePaths,
+			Branches: *keysCreateBranches,
+		}, *keysCreateExpires)
+	case keysList.FullCommand():
+		ListKeys(*dbPath, *keysListEmail)
+	case keysRevoke.FullCommand():
+		RevokeKey(*dbPath, *keysRevokeEmail, *keysRevokeName)
+	case rolesList.FullCommand():
+		ListRoles(*dbPath)
+	case rolesAdd.FullCommand():
+		AddRole(*dbPath, *rolesAddName, *rolesAddAllow, *rolesAddDeny)
+	case rolesDel.FullCommand():
+		DeleteRole(*dbPath, *rolesDelName)
+	case groupsList.FullCommand():
+		ListGroups(*dbPath)
+	case groupsAdd.FullCommand():
+		AddGroup(*dbPath, *groupsAddName, *groupsAddAllow, *groupsAddDeny, *groupsAddRole)
+	case groupsDel.FullCommand():
+		DeleteGroup(*dbPath, *groupsDelName)
 	}
 }
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/netlify/netlify-git-api/auth"
+	"github.com/netlify/netlify-git-api/lockout"
+	"github.com/netlify/netlify-git-api/repo"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the server configuration file
+type Config struct {
+	ProtectedBranches []*repo.BranchProtection `yaml:"protected_branches"`
+	Tokens            TokenConfig              `yaml:"tokens"`
+	Auth              AuthConfig               `yaml:"auth"`
+	Lockout           lockout.Config           `yaml:"lockout"`
+	UserDB            UserDBConfig             `yaml:"userdb"`
+	ClientIPHeader    string                   `yaml:"client_ip_header"`
+}
+
+// UserDBConfig configures how often a running server checks the user db for
+// changes. A ReloadInterval of 0 only reloads on SIGHUP.
+type UserDBConfig struct {
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+}
+
+// AuthConfig configures authentication. Backend can be "userdb", "htpasswd"
+// or "ldap". Users from htpasswd or LDAP that aren't in the user db get the
+// default roles. AllowedOrigins configures the auth popup.
+type AuthConfig struct {
+	Backend        string           `yaml:"backend"`
+	Htpasswd       string           `yaml:"htpasswd"`
+	LDAP           *auth.LDAPConfig `yaml:"ldap"`
+	DefaultRoles   []string         `yaml:"default_roles"`
+	AllowedOrigins []string         `yaml:"allowed_origins"`
+}
+
+// TokenConfig configures where tokens are stored and how long they are valid.
+// Secret is the signing secret for the jwt token mode.
+type TokenConfig struct {
+	Path       string        `yaml:"path"`
+	Secret     string        `yaml:"secret"`
+	AccessTTL  time.Duration `yaml:"access_ttl"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl"`
+}
+
+// ReadConfig reads the server config from a filepath. A missing file gives
+// the default config.
+func ReadConfig(configPath string) (*Config, error) {
+	config := &Config{
+		Auth: AuthConfig{Backend: "userdb"},
+		Tokens: TokenConfig{
+			Path:       ".netlify-git-api-tokens.json",
+			AccessTTL:  time.Hour,
+			RefreshTTL: 30 * 24 * time.Hour,
+		},
+		UserDB: UserDBConfig{ReloadInterval: 5 * time.Second},
+		Lockout: lockout.Config{
+			Path:        ".netlify-git-api-lockout.json",
+			DelayAfter:  3,
+			MaxFailures: 10,
+			BaseDelay:   time.Second,
+			MaxDelay:    5 * time.Minute,
+			Duration:    15 * time.Minute,
+		},
+	}
+	data, err := ioutil.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	err = yaml.Unmarshal(data, config)
+	return config, err
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/netlify/netlify-git-api/userdb"
+)
+
+// CreateKey creates a scoped API key for a user and prints it
+func CreateKey(dbPath, email, name string, scope userdb.KeyScope, ttl time.Duration) {
+	var value string
+	err := userdb.NewStore(dbPath).UpdateUser(email, func(user *userdb.User) error {
+		var err error
+		value, _, err = user.CreateKey(name, scope, ttl)
+		return err
+	})
+	if err != nil {
+		log.Fatalf("Error: Could not create key: %v", err)
+	}
+
+	log.Printf("Created key %v for %v, it won't be shown again:", name, email)
+	fmt.Println(value)
+}
+
+// ListKeys lists the API keys of a user, or of all users if email is empty
+func ListKeys(dbPath, email string) {
+	store := userdb.NewStore(dbPath)
+	var users []userdb.User
+	if email != "" {
+		user, err := store.LookupByEmail(email)
+		if err != nil {
+			log.Fatalf("Error: failed to read db %v: %v\n", dbPath, err)
+		}
+		if user != nil {
+			users = append(users, *user)
+		}
+	} else {
+		db, err := store.Load()
+		if err != nil {
+			log.Fatalf("Error: failed to read db %v: %v\n", dbPath, err)
+		}
+		users = db.Users
+	}
+
+	found := false
+	for _, user := range users {
+		for _, key := range user.Keys {
+			found = true
+			log.Printf("%v: %v %v\n", user.Email, key.Name, formatKey(&key))
+		}
+	}
+	if !found {
+		log.Printf("No keys found in %v\n", dbPath)
+	}
+}
+
+// RevokeKey removes an API key from a user
+func RevokeKey(dbPath, email, name string) {
+	err := userdb.NewStore(dbPath).UpdateUser(email, func(user *userdb.User) error {
+		if !user.RevokeKey(name) {
+			return fmt.Errorf("%v has no key named %v", email, name)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Error: Could not revoke key: %v", err)
+	}
+
+	log.Printf("Revoked key %v of %v", name, email)
+}
+
+// formatKey describes the scope and expiry of a key
+func formatKey(key *userdb.APIKey) string {
+	access := "read"
+	if key.Scope.Write {
+		access = "write"
+	}
+	parts := []string{access}
+	if len(key.Scope.Paths) > 0 {
+		parts = append(parts, "paths: "+strings.Join(key.Scope.Paths, ","))
+	}
+	if len(key.Scope.Branches) > 0 {
+		parts = append(parts, "branches: "+strings.Join(key.Scope.Branches, ","))
+	}
+	switch {
+	case key.ExpiresAt == nil:
+		parts = append(parts, "never expires")
+	case key.Expired():
+		parts = append(parts, "expired "+key.ExpiresAt.Format(time.RFC3339))
+	default:
+		parts = append(parts, "expires "+key.ExpiresAt.Format(time.RFC3339))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/netlify/netlify-git-api/userdb"
+)
+
+func TestKeyUser(t *testing.T) {
+	db := &userdb.UserDB{}
+	writer := &userdb.User{Permissions: userdb.Permissions{"*": {Allow: []string{"content/**", "static/**"}}}}
+	viewer := &userdb.User{Roles: []string{"viewer"}}
+
+	tests := []struct {
+		name     string
+		user     *userdb.User
+		scope    userdb.KeyScope
+		path     string
+		allowed  bool
+		readOnly bool
+	}{
+		{name: "read only key", user: writer, scope: userdb.KeyScope{}, path: "content/post.md", allowed: true, readOnly: true},
+		{name: "write key", user: writer, scope: userdb.KeyScope{Write: true}, path: "content/post.md", allowed: true},
+		{name: "write key outside the user's permissions", user: writer, scope: userdb.KeyScope{Write: true}, path: "config.yml", allowed: false},
+		{name: "path in scope", user: writer, scope: userdb.KeyScope{Write: true, Paths: []string{"content/**"}}, path: "content/post.md", allowed: true},
+		{name: "path outside scope", user: writer, scope: userdb.KeyScope{Write: true, Paths: []string{"content/**"}}, path: "static/logo.png", allowed: false},
+		{name: "write key of a read only user", user: viewer, scope: userdb.KeyScope{Write: true}, path: "content/post.md", allowed: false, readOnly: true},
+	}
+
+	for _, test := range tests {
+		key := &userdb.APIKey{Scope: test.scope}
+		user := &keyUser{userWrapper: &userWrapper{dbUser: test.user, db: db}, key: key}
+		if allowed := user.HasPermission("update", test.path); allowed != test.allowed {
+			t.Errorf("%v: expected HasPermission %v, got %v", test.name, test.allowed, allowed)
+		}
+		if readOnly := user.ReadOnly(); readOnly != test.readOnly {
+			t.Errorf("%v: expected ReadOnly %v, got %v", test.name, test.readOnly, readOnly)
+		}
+	}
+}
+
+func TestFormatKey(t *testing.T) {
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	expiredAt := time.Date(2010, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		key      *userdb.APIKey
+		expected string
+	}{
+		{key: &userdb.APIKey{}, expected: "read never expires"},
+		{
+			key:      &userdb.APIKey{Scope: userdb.KeyScope{Write: true, Paths: []string{"content/**", "static/**"}, Branches: []string{"cms/*"}}},
+			expected: "write paths: content/**,static/** branches: cms/* never expires",
+		},
+		{key: &userdb.APIKey{ExpiresAt: &expiresAt}, expected: "read expires 2030-01-02T03:04:05Z"},
+		{key: &userdb.APIKey{ExpiresAt: &expiredAt}, expected: "read expired 2010-01-02T03:04:05Z"},
+	}
+
+	for _, test := range tests {
+		if formatted := formatKey(test.key); formatted != test.expected {
+			t.Errorf("formatKey: expected %q, got %q", test.expected, formatted)
+		}
+	}
+}
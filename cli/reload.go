@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/netlify/netlify-git-api/userdb"
+)
+
+// watchUserDB reloads the user db on SIGHUP and every interval, and revokes
+// the tokens of users that were removed or changed their password
+func watchUserDB(users *userdb.Live, tokens tokenIssuer, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-hup:
+			log.Printf("Reloading user db on SIGHUP\n")
+		case <-tick:
+		}
+		reloadUserDB(users, tokens)
+	}
+}
+
+func reloadUserDB(users *userdb.Live, tokens tokenIssuer) {
+	changed, err := users.Reload()
+	if err != nil {
+		log.Printf("Error reloading user db: %v\n", err)
+		return
+	}
+
+	for _, id := range changed {
+		if err := tokens.revokeUser(id); err != nil {
+			log.Printf("Error revoking tokens of %v: %v\n", id, err)
+		}
+	}
+	if len(changed) > 0 {
+		log.Printf("Reloaded user db, revoked tokens of %v users\n", len(changed))
+	}
+}
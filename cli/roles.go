@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/netlify/netlify-git-api/userdb"
+)
+
+// ListRoles lists all roles and their permissions
+func ListRoles(dbPath string) {
+	db, err := userdb.NewStore(dbPath).LoadRoles()
+	if err != nil {
+		log.Fatalf("Error: failed to read db %v: %v\n", dbPath, err)
+	}
+
+	for _, role := range db.AllRoles() {
+		log.Printf("%v: %v\n", role.Name, formatPermissions(role.Permissions))
+	}
+}
+
+// AddRole adds or replaces a role with allow and deny rules in the form
+// action:pattern. Rules without an action apply to every action.
+func AddRole(dbPath, name string, allow, deny []string) {
+	err := userdb.NewStore(dbPath).Update(func(db *userdb.UserDB) error {
+		db.AddRole(name, parsePermissions(allow, deny))
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Error: Could not write db %v: %v", dbPath, err)
+	}
+
+	log.Printf("Role %v added", name)
+}
+
+// DeleteRole removes a role
+func DeleteRole(dbPath, name string) {
+	err := userdb.NewStore(dbPath).Update(func(db *userdb.UserDB) error {
+		return db.DeleteRole(name)
+	})
+	if err != nil {
+		log.Fatalf("Error: Could not delete role %v: %v", name, err)
+	}
+
+	log.Printf("Role %v deleted", name)
+}
+
+// ListGroups lists all groups with their roles and permissions
+func ListGroups(dbPath string) {
+	db, err := userdb.NewStore(dbPath).LoadRoles()
+	if err != nil {
+		log.Fatalf("Error: failed to read db %v: %v\n", dbPath, err)
+	}
+
+	for _, group := range db.Groups {
+		if len(group.Roles) > 0 {
+			log.Printf("%v (roles: %v): %v\n", group.Name, strings.Join(group.Roles, ", "), formatPermissions(group.Permissions))
+			continue
+		}
+		log.Printf("%v: %v\n", group.Name, formatPermissions(group.Permissions))
+	}
+}
+
+// AddGroup adds or replaces a group with allow and deny rules in the form
+// action:pattern and the roles its members get. Rules without an action
+// apply to every action.
+func AddGroup(dbPath, name string, allow, deny, roles []string) {
+	err := userdb.NewStore(dbPath).Update(func(db *userdb.UserDB) error {
+		return db.AddGroup(name, parsePermissions(allow, deny), roles)
+	})
+	if err != nil {
+		log.Fatalf("Error: Could not write db %v: %v", dbPath, err)
+	}
+
+	log.Printf("Group %v added", name)
+}
+
+// DeleteGroup removes a group and all users from it
+func DeleteGroup(dbPath, name string) {
+	err := userdb.NewStore(dbPath).Update(func(db *userdb.UserDB) error {
+		return db.DeleteGroup(name)
+	})
+	if err != nil {
+		log.Fatalf("Error: Could not delete group %v: %v", name, err)
+	}
+
+	log.Printf("Group %v deleted", name)
+}
+
+// parsePermissions builds permissions from allow and deny rules in the form
+// action:pattern
+func parsePermissions(allow, deny []string) userdb.Permissions {
+	permissions := userdb.Permissions{}
+	for _, rule := range allow {
+		action, pattern := parseRule(rule)
+		rules := permissionRules(permissions, action)
+		rules.Allow = append(rules.Allow, pattern)
+	}
+	for _, rule := range deny {
+		action, pattern := parseRule(rule)
+		rules := permissionRules(permissions, action)
+		rules.Deny = append(rules.Deny, pattern)
+	}
+	return permissions
+}
+
+func parseRule(rule string) (string, string) {
+	parts := strings.SplitN(rule, ":", 2)
+	if len(parts) == 1 {
+		return "*", parts[0]
+	}
+	return parts[0], parts[1]
+}
+
+func permissionRules(permissions userdb.Permissions, action string) *userdb.PathRules {
+	rules, ok := permissions[action]
+	if !ok {
+		rules = &userdb.PathRules{}
+		permissions[action] = rules
+	}
+	return rules
+}
+
+func formatPermissions(permissions userdb.Permissions) string {
+	if len(permissions) == 0 {
+		return "no permissions"
+	}
+
+	actions := []string{}
+	for action := range permissions {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+
+	parts := []string{}
+	for _, action := range actions {
+		rules := permissions[action]
+		if rules == nil {
+			continue
+		}
+		if len(rules.Allow) > 0 {
+			parts = append(parts, fmt.Sprintf("%v allow %v", action, strings.Join(rules.Allow, ",")))
+		}
+		if len(rules.Deny) > 0 {
+			parts = append(parts, fmt.Sprintf("%v deny %v", action, strings.Join(rules.Deny, ",")))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
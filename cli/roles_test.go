@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/netlify/netlify-git-api/userdb"
+)
+
+func TestParsePermissions(t *testing.T) {
+	tests := []struct {
+		allow    []string
+		deny     []string
+		expected userdb.Permissions
+	}{
+		{expected: userdb.Permissions{}},
+		{
+			allow:    []string{"content/**"},
+			expected: userdb.Permissions{"*": {Allow: []string{"content/**"}}},
+		},
+		{
+			allow: []string{"update:content/**", "create:content/**", "static/**"},
+			deny:  []string{"delete:content/**", "*:config.yml"},
+			expected: userdb.Permissions{
+				"update": {Allow: []string{"content/**"}},
+				"create": {Allow: []string{"content/**"}},
+				"delete": {Deny: []string{"content/**"}},
+				"*":      {Allow: []string{"static/**"}, Deny: []string{"config.yml"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		if permissions := parsePermissions(test.allow, test.deny); !reflect.DeepEqual(permissions, test.expected) {
+			t.Errorf("parsePermissions(%v, %v): expected %v, got %v", test.allow, test.deny, formatPermissions(test.expected), formatPermissions(permissions))
+		}
+	}
+}
+
+func TestFormatPermissions(t *testing.T) {
+	tests := []struct {
+		permissions userdb.Permissions
+		expected    string
+	}{
+		{permissions: nil, expected: "no permissions"},
+		{permissions: userdb.Permissions{"*": {Allow: []string{"**"}}}, expected: "* allow **"},
+		{
+			permissions: userdb.Permissions{"update": {Allow: []string{"a/**", "b/**"}}, "*": {Deny: []string{"c.yml"}}},
+			expected:    "* deny c.yml; update allow a/**,b/**",
+		},
+	}
+
+	for _, test := range tests {
+		if formatted := formatPermissions(test.permissions); formatted != test.expected {
+			t.Errorf("formatPermissions(%v): expected %q, got %q", test.permissions, test.expected, formatted)
+		}
+	}
+}
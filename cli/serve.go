@@ -8,13 +8,16 @@ import (
 	"strings"
 
 	"github.com/netlify/netlify-git-api/api"
+	"github.com/netlify/netlify-git-api/auth"
+	"github.com/netlify/netlify-git-api/lockout"
 	"github.com/netlify/netlify-git-api/repo"
+	"github.com/netlify/netlify-git-api/tokenstore"
 	"github.com/netlify/netlify-git-api/userdb"
-	"github.com/pborman/uuid"
 )
 
 type userWrapper struct {
 	dbUser *userdb.User
+	db     *userdb.UserDB
 }
 
 func (u *userWrapper) Name() string {
@@ -25,78 +28,214 @@ func (u *userWrapper) Email() string {
 	return u.dbUser.Email
 }
 
-func (u *userWrapper) HasPermission(_ string, _ string) bool {
-	return true
+func (u *userWrapper) HasPermission(action string, path string) bool {
+	return u.db.HasPermission(u.dbUser, action, path)
+}
+
+func (u *userWrapper) HasRole(role string) bool {
+	return u.db.HasRole(u.dbUser, role)
+}
+
+func (u *userWrapper) ReadOnly() bool {
+	return u.db.IsReadOnly(u.dbUser)
+}
+
+// keyUser is a user authenticated with an API key, limited to the key's scope
+type keyUser struct {
+	*userWrapper
+	key *userdb.APIKey
+}
+
+func (u *keyUser) HasPermission(action string, path string) bool {
+	return u.key.Scope.AllowsPath(path) && u.userWrapper.HasPermission(action, path)
+}
+
+func (u *keyUser) ReadOnly() bool {
+	return !u.key.Scope.Write || u.userWrapper.ReadOnly()
 }
 
 type resolver struct {
-	db       *userdb.UserDB
+	store    userdb.Store
+	users    *userdb.Live
+	auth     auth.Authenticator
+	config   *Config
 	repoPath string
-	tokens   map[string]string
+	tokens   tokenIssuer
+	totp     *userdb.TOTPGuard
 	sync     bool
 }
 
 func (r *resolver) GetRepo(req *http.Request) (*repo.Repo, error) {
-	authHeader := req.Header.Get("Authorization")
-	if !strings.HasPrefix(authHeader, "Bearer ") {
+	token, ok := api.BearerToken(req)
+	if !ok {
 		return nil, nil
 	}
-
-	parts := strings.Split(authHeader, " ")
-	if len(parts) != 2 {
-		return nil, nil
+	if strings.HasPrefix(token, userdb.KeyPrefix) {
+		return r.getRepoForKey(token)
 	}
-	id, ok := r.tokens[parts[1]]
-	if !ok {
-		return nil, nil
+
+	user, err := r.tokens.validate(token, tokenstore.Access)
+	if err != nil || user == nil || user.Disabled {
+		return nil, err
 	}
 
-	user := r.db.Get(id)
-	if user == nil {
-		return nil, nil
+	return r.openRepo(&userWrapper{dbUser: user, db: r.users.DB()}), nil
+}
+
+// getRepoForKey opens the repo for the user of an API key
+func (r *resolver) getRepoForKey(value string) (*repo.Repo, error) {
+	user, key, err := userdb.LookupKey(r.store, value)
+	if err != nil || user == nil || user.Disabled {
+		return nil, err
 	}
 
-	currentRepo, err := repo.Open(&userWrapper{dbUser: user}, r.repoPath, r.sync)
+	currentRepo := r.openRepo(&keyUser{userWrapper: &userWrapper{dbUser: user, db: r.users.DB()}, key: key})
+	currentRepo.RestrictBranches(key.Scope.Branches)
+	return currentRepo, nil
+}
+
+func (r *resolver) openRepo(user repo.User) *repo.Repo {
+	currentRepo, err := repo.Open(user, r.repoPath, r.sync)
 	if err != nil {
 		panic(fmt.Sprintf("Unable to open git repository in %v: %v", r.repoPath, err))
 	}
+	currentRepo.Protect(r.config.ProtectedBranches)
 
-	return currentRepo, nil
+	return currentRepo
 }
 
-func (r *resolver) Authenticate(email, pw string) (string, error) {
-	user := r.db.LookupByEmail(email)
-	if user == nil {
-		return "", nil
+func (r *resolver) Authenticate(email, pw, otp string) (*api.Token, error) {
+	user, err := r.auth.Authenticate(email, pw)
+	if err != nil || user == nil || user.Disabled {
+		return nil, err
 	}
-	if !user.Authenticate(pw) {
-		return "", nil
+
+	if user.TOTPEnabled() {
+		if otp == "" {
+			return nil, api.ErrOTPRequired
+		}
+		if !r.totp.Verify(user, otp) {
+			return nil, nil
+		}
 	}
 
-	token := uuid.New()
+	token, err := r.tokens.issue(user)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.store.RecordLogin(user.ID); err != nil {
+		log.Printf("Error recording login of %v: %v\n", user.Email, err)
+	}
+	return token, nil
+}
 
-	r.tokens[token] = user.ID
+func (r *resolver) Refresh(refreshToken string) (*api.Token, error) {
+	user, err := r.tokens.validate(refreshToken, tokenstore.Refresh)
+	if err != nil || user == nil || user.Disabled {
+		return nil, err
+	}
 
-	return token, nil
+	// Refresh tokens can only be used once
+	if err := r.tokens.revoke(refreshToken); err != nil {
+		return nil, err
+	}
+
+	return r.tokens.issue(user)
+}
+
+func (r *resolver) Revoke(token string) error {
+	return r.tokens.revoke(token)
+}
+
+// ServeOptions are the command line options for Serve. AuthBackend and
+// Htpasswd override the settings from the config file when set.
+type ServeOptions struct {
+	ConfigPath  string
+	Host        string
+	Port        string
+	TokenMode   string
+	AuthBackend string
+	Htpasswd    string
+	Sync        bool
+	ReadOnly    bool
 }
 
 // Serve starts a new REST API server
-func Serve(dbPath, host, port string, sync bool) {
+func Serve(dbPath string, options *ServeOptions) {
 	cwd, err := os.Getwd()
 	if err != nil {
 		log.Fatalf("Error getting current working dir: %v\n", err)
 	}
 
-	userDB, err := userdb.Read(dbPath)
+	config, err := ReadConfig(options.ConfigPath)
+	if err != nil {
+		log.Fatalf("Error reading config %v: %v\n", options.ConfigPath, err)
+	}
+	if options.AuthBackend != "" {
+		config.Auth.Backend = options.AuthBackend
+	}
+	if options.Htpasswd != "" {
+		config.Auth.Htpasswd = options.Htpasswd
+	}
+
+	store := userdb.NewStore(dbPath)
+	users, err := userdb.NewLive(store)
 	if err != nil {
 		log.Fatalf("Error reading user db %v: %v\n", dbPath, err)
 	}
-	if len(userDB.Users) == 0 {
-		log.Fatalf("Error - no users in user db %v\n", dbPath)
+
+	var authenticator auth.Authenticator
+	switch config.Auth.Backend {
+	case "htpasswd":
+		if config.Auth.Htpasswd == "" {
+			log.Fatalf("Error - the htpasswd backend needs the path to an htpasswd file\n")
+		}
+		authenticator = auth.NewHtpasswd(config.Auth.Htpasswd, store, config.Auth.DefaultRoles)
+	case "ldap":
+		if config.Auth.LDAP == nil || config.Auth.LDAP.Addr == "" {
+			log.Fatalf("Error - the ldap backend needs an ldap section with an addr in the config\n")
+		}
+		authenticator = auth.NewLDAP(config.Auth.LDAP, store, config.Auth.DefaultRoles)
+	case "userdb":
+		db, err := store.Load()
+		if err != nil {
+			log.Fatalf("Error reading user db %v: %v\n", dbPath, err)
+		}
+		if len(db.Users) == 0 {
+			log.Fatalf("Error - no users in user db %v\n", dbPath)
+		}
+		authenticator = auth.NewUserDB(store)
+	default:
+		log.Fatalf("Error - unknown auth backend %v\n", config.Auth.Backend)
+	}
+
+	var tokens tokenIssuer
+	switch options.TokenMode {
+	case "jwt":
+		secret := config.Tokens.Secret
+		if env := os.Getenv("NETLIFY_GIT_API_JWT_SECRET"); env != "" {
+			secret = env
+		}
+		if secret == "" {
+			log.Fatalf("Error - jwt token mode needs a secret in the config or NETLIFY_GIT_API_JWT_SECRET\n")
+		}
+		tokens = &jwtTokens{secret: []byte(secret), accessTTL: config.Tokens.AccessTTL, auth: authenticator}
+	default:
+		tokenStore, err := tokenstore.NewFileStore(config.Tokens.Path)
+		if err != nil {
+			log.Fatalf("Error reading token store %v: %v\n", config.Tokens.Path, err)
+		}
+		tokens = &storeTokens{store: tokenStore, accessTTL: config.Tokens.AccessTTL, refreshTTL: config.Tokens.RefreshTTL, auth: authenticator}
 	}
 
-	resolver := &resolver{db: userDB, repoPath: cwd, tokens: map[string]string{}, sync: sync}
+	resolver := &resolver{store: store, users: users, auth: authenticator, config: config, repoPath: cwd, tokens: tokens, totp: userdb.NewTOTPGuard(), sync: options.Sync}
+	go watchUserDB(users, tokens, config.UserDB.ReloadInterval)
 
-	api := api.NewAPI(resolver)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf("%v:%v", host, port), api))
+	api := api.NewAPI(resolver, &api.Config{
+		ReadOnly:       options.ReadOnly,
+		AllowedOrigins: config.Auth.AllowedOrigins,
+		Lockout:        lockout.New(&config.Lockout),
+		ClientIPHeader: config.ClientIPHeader,
+	})
+	log.Fatal(http.ListenAndServe(fmt.Sprintf("%v:%v", options.Host, options.Port), api))
 }
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"time"
+
+	"github.com/netlify/netlify-git-api/api"
+	"github.com/netlify/netlify-git-api/auth"
+	"github.com/netlify/netlify-git-api/jwt"
+	"github.com/netlify/netlify-git-api/tokenstore"
+	"github.com/netlify/netlify-git-api/userdb"
+	"github.com/pborman/uuid"
+)
+
+// tokenIssuer issues and validates the tokens for a token mode
+type tokenIssuer interface {
+	// issue grants an access and a refresh token to a user
+	issue(user *userdb.User) (*api.Token, error)
+	// validate returns the user a token of a kind was issued to or nil if
+	// the token isn't valid or the user no longer exists
+	validate(token, kind string) (*userdb.User, error)
+	// revoke invalidates a token
+	revoke(token string) error
+	// revokeUser invalidates all tokens issued to a user
+	revokeUser(userID string) error
+}
+
+// storeTokens are random tokens kept in a token store
+type storeTokens struct {
+	store      tokenstore.Store
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	auth       auth.Authenticator
+}
+
+func (t *storeTokens) issue(user *userdb.User) (*api.Token, error) {
+	accessToken := uuid.New()
+	if err := t.store.Save(accessToken, tokenstore.New(tokenstore.Access, user.ID, t.accessTTL)); err != nil {
+		return nil, err
+	}
+
+	refreshToken := uuid.New()
+	if err := t.store.Save(refreshToken, tokenstore.New(tokenstore.Refresh, user.ID, t.refreshTTL)); err != nil {
+		return nil, err
+	}
+
+	return &api.Token{
+		AccessToken:  accessToken,
+		TokenType:    "bearer",
+		ExpiresIn:    int(t.accessTTL.Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (t *storeTokens) validate(token, kind string) (*userdb.User, error) {
+	stored, err := t.store.Get(token)
+	if err != nil || stored == nil || stored.Kind != kind {
+		return nil, err
+	}
+	return t.auth.Get(stored.UserID), nil
+}
+
+func (t *storeTokens) revoke(token string) error {
+	return t.store.Delete(token)
+}
+
+func (t *storeTokens) revokeUser(userID string) error {
+	return t.store.DeleteUser(userID)
+}
+
+// jwtTokens are signed tokens that any server sharing the secret can validate
+// without shared state. Single tokens can't be revoked before they expire.
+// Tokens carry a fingerprint of the user's password hash, so all tokens of a
+// user are rejected once their password changes, on every server and after
+// restarts. No refresh tokens are issued, since a refresh token that can't be
+// consumed could be exchanged for access tokens over and over until it
+// expires.
+type jwtTokens struct {
+	secret    []byte
+	accessTTL time.Duration
+	auth      auth.Authenticator
+}
+
+func (t *jwtTokens) issue(user *userdb.User) (*api.Token, error) {
+	now := time.Now()
+	accessToken, err := jwt.Sign(&jwt.Claims{
+		Subject:     user.ID,
+		Type:        tokenstore.Access,
+		Fingerprint: t.fingerprint(user),
+		IssuedAt:    now.Unix(),
+		ExpiresAt:   now.Add(t.accessTTL).Unix(),
+	}, t.secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.Token{
+		AccessToken: accessToken,
+		TokenType:   "bearer",
+		ExpiresIn:   int(t.accessTTL.Seconds()),
+	}, nil
+}
+
+func (t *jwtTokens) validate(token, kind string) (*userdb.User, error) {
+	if kind == tokenstore.Refresh {
+		return nil, api.ErrNotSupported
+	}
+
+	claims, err := jwt.Parse(token, t.secret)
+	if err != nil || claims.Type != kind {
+		return nil, nil
+	}
+
+	user := t.auth.Get(claims.Subject)
+	if user == nil || !hmac.Equal([]byte(claims.Fingerprint), []byte(t.fingerprint(user))) {
+		return nil, nil
+	}
+	return user, nil
+}
+
+// fingerprint identifies the password hash of a user without revealing it
+func (t *jwtTokens) fingerprint(user *userdb.User) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(user.PasswordHash))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+func (t *jwtTokens) revoke(token string) error {
+	return api.ErrNotSupported
+}
+
+// revokeUser has nothing to do, tokens of removed users and users whose
+// password changed are rejected by validate
+func (t *jwtTokens) revokeUser(userID string) error {
+	return nil
+}
@@ -0,0 +1,208 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/netlify/netlify-git-api/api"
+	"github.com/netlify/netlify-git-api/auth"
+	"github.com/netlify/netlify-git-api/jwt"
+	"github.com/netlify/netlify-git-api/tokenstore"
+	"github.com/netlify/netlify-git-api/userdb"
+)
+
+// testTokenUsers returns a store with two users and an authenticator for it
+func testTokenUsers(t *testing.T) (userdb.Store, auth.Authenticator) {
+	db := &userdb.UserDB{}
+	for _, id := range []string{"user-1", "user-2"} {
+		user, err := db.Add(id+"@example.com", "", "secret")
+		if err != nil {
+			t.Fatalf("Error adding user: %v", err)
+		}
+		user.ID = id
+	}
+	store := userdb.NewMemoryStore(db)
+	return store, auth.NewUserDB(store)
+}
+
+func userID(user *userdb.User) string {
+	if user == nil {
+		return ""
+	}
+	return user.ID
+}
+
+func TestStoreTokens(t *testing.T) {
+	store, authenticator := testTokenUsers(t)
+	tokens := &storeTokens{store: tokenstore.NewMemoryStore(), accessTTL: time.Hour, refreshTTL: time.Hour, auth: authenticator}
+	user, _ := store.Get("user-1")
+	other, _ := store.Get("user-2")
+
+	issued, err := tokens.issue(user)
+	if err != nil {
+		t.Fatalf("Error issuing tokens: %v", err)
+	}
+	otherIssued, err := tokens.issue(other)
+	if err != nil {
+		t.Fatalf("Error issuing tokens: %v", err)
+	}
+
+	tests := []struct {
+		action string
+		token  string
+		kind   string
+		userID string
+	}{
+		{token: issued.AccessToken, kind: tokenstore.Access, userID: "user-1"},
+		{token: issued.RefreshToken, kind: tokenstore.Refresh, userID: "user-1"},
+		{token: issued.AccessToken, kind: tokenstore.Refresh},
+		{token: issued.RefreshToken, kind: tokenstore.Access},
+		{token: "unknown", kind: tokenstore.Access},
+		{action: "revoke", token: issued.AccessToken},
+		{token: issued.AccessToken, kind: tokenstore.Access},
+		{token: issued.RefreshToken, kind: tokenstore.Refresh, userID: "user-1"},
+		{action: "revoke user", token: "user-1"},
+		{token: issued.RefreshToken, kind: tokenstore.Refresh},
+		{token: otherIssued.AccessToken, kind: tokenstore.Access, userID: "user-2"},
+	}
+
+	for i, test := range tests {
+		switch test.action {
+		case "revoke":
+			if err := tokens.revoke(test.token); err != nil {
+				t.Errorf("%v: error revoking: %v", i, err)
+			}
+			continue
+		case "revoke user":
+			if err := tokens.revokeUser(test.token); err != nil {
+				t.Errorf("%v: error revoking user: %v", i, err)
+			}
+			continue
+		}
+
+		user, err := tokens.validate(test.token, test.kind)
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", i, err)
+		}
+		if userID(user) != test.userID {
+			t.Errorf("%v: expected %q, got %q", i, test.userID, userID(user))
+		}
+	}
+}
+
+func TestJWTTokens(t *testing.T) {
+	store, authenticator := testTokenUsers(t)
+	tokens := &jwtTokens{secret: []byte("secret"), accessTTL: time.Hour, auth: authenticator}
+	user, _ := store.Get("user-1")
+
+	issued, err := tokens.issue(user)
+	if err != nil {
+		t.Fatalf("Error issuing tokens: %v", err)
+	}
+	if issued.RefreshToken != "" {
+		t.Errorf("Expected no refresh token, got %v", issued.RefreshToken)
+	}
+	otherSecret := &jwtTokens{secret: []byte("other"), accessTTL: time.Hour, auth: authenticator}
+	forged, err := otherSecret.issue(user)
+	if err != nil {
+		t.Fatalf("Error issuing tokens: %v", err)
+	}
+
+	sign := func(claims *jwt.Claims) string {
+		claims.ExpiresAt = time.Now().Add(time.Hour).Unix()
+		token, err := jwt.Sign(claims, tokens.secret)
+		if err != nil {
+			t.Fatalf("Error signing token: %v", err)
+		}
+		return token
+	}
+	refresh := sign(&jwt.Claims{Subject: "user-1", Type: tokenstore.Refresh, Fingerprint: tokens.fingerprint(user)})
+	noFingerprint := sign(&jwt.Claims{Subject: "user-1", Type: tokenstore.Access})
+	unknownUser := sign(&jwt.Claims{Subject: "nobody", Type: tokenstore.Access, Fingerprint: tokens.fingerprint(&userdb.User{})})
+
+	tests := []struct {
+		name   string
+		token  string
+		kind   string
+		userID string
+		err    error
+	}{
+		{name: "access token", token: issued.AccessToken, kind: tokenstore.Access, userID: "user-1"},
+		{name: "refresh grant", token: issued.AccessToken, kind: tokenstore.Refresh, err: api.ErrNotSupported},
+		{name: "refresh token", token: refresh, kind: tokenstore.Refresh, err: api.ErrNotSupported},
+		{name: "refresh token as access token", token: refresh, kind: tokenstore.Access},
+		{name: "without fingerprint", token: noFingerprint, kind: tokenstore.Access},
+		{name: "unknown user", token: unknownUser, kind: tokenstore.Access},
+		{name: "other secret", token: forged.AccessToken, kind: tokenstore.Access},
+		{name: "malformed", token: "not-a-token", kind: tokenstore.Access},
+	}
+
+	for _, test := range tests {
+		user, err := tokens.validate(test.token, test.kind)
+		if err != test.err {
+			t.Errorf("%v: expected error %v, got %v", test.name, test.err, err)
+		}
+		if userID(user) != test.userID {
+			t.Errorf("%v: expected %q, got %q", test.name, test.userID, userID(user))
+		}
+	}
+
+	if err := tokens.revoke(issued.AccessToken); err != api.ErrNotSupported {
+		t.Errorf("Expected revoking a single token to be unsupported, got %v", err)
+	}
+}
+
+func TestJWTTokensPasswordChange(t *testing.T) {
+	store, authenticator := testTokenUsers(t)
+	tokens := &jwtTokens{secret: []byte("secret"), accessTTL: time.Hour, auth: authenticator}
+	user, _ := store.Get("user-1")
+	other, _ := store.Get("user-2")
+
+	old, err := tokens.issue(user)
+	if err != nil {
+		t.Fatalf("Error issuing tokens: %v", err)
+	}
+	otherIssued, err := tokens.issue(other)
+	if err != nil {
+		t.Fatalf("Error issuing tokens: %v", err)
+	}
+
+	err = store.UpdateUser(user.Email, func(user *userdb.User) error {
+		return user.SetPassword("changed")
+	})
+	if err != nil {
+		t.Fatalf("Error changing password: %v", err)
+	}
+	user, _ = store.Get("user-1")
+	issued, err := tokens.issue(user)
+	if err != nil {
+		t.Fatalf("Error issuing tokens: %v", err)
+	}
+
+	// A server started after the change has no state of its own
+	restarted := &jwtTokens{secret: tokens.secret, accessTTL: time.Hour, auth: authenticator}
+
+	tests := []struct {
+		name   string
+		token  string
+		userID string
+	}{
+		{name: "issued before the change", token: old.AccessToken},
+		{name: "issued after the change", token: issued.AccessToken, userID: "user-1"},
+		{name: "other user", token: otherIssued.AccessToken, userID: "user-2"},
+	}
+	for _, test := range tests {
+		for _, server := range []*jwtTokens{tokens, restarted} {
+			if user, _ := server.validate(test.token, tokenstore.Access); userID(user) != test.userID {
+				t.Errorf("%v: expected %q, got %q", test.name, test.userID, userID(user))
+			}
+		}
+	}
+
+	if err := store.DeleteUser("user-2@example.com"); err != nil {
+		t.Fatalf("Error deleting user: %v", err)
+	}
+	if user, _ := tokens.validate(otherIssued.AccessToken, tokenstore.Access); user != nil {
+		t.Errorf("Expected the tokens of a deleted user to be rejected")
+	}
+}
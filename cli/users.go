@@ -1,10 +1,14 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/netlify/netlify-git-api/lockout"
 	"github.com/netlify/netlify-git-api/userdb"
 	"golang.org/x/crypto/ssh/terminal"
 )
@@ -22,30 +26,122 @@ func promptPassword() (string, error) {
 	return fmt.Sprintf("%s", bytes), err
 }
 
-// ListUsers lists all users
-func ListUsers(dbPath string) {
-	db, err := userdb.Read(dbPath)
+// userInfo is a user as printed by `users list --json` and `users show`,
+// without password hashes and secrets
+type userInfo struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Email     string   `json:"email"`
+	Roles     []string `json:"roles"`
+	Groups    []string `json:"groups"`
+	TwoFactor bool     `json:"two_factor"`
+	Disabled  bool     `json:"disabled"`
+	Keys      int      `json:"keys"`
+}
+
+// userDetails is a user as printed by `users show` with its last login
+type userDetails struct {
+	*userInfo
+	LastLogin *time.Time `json:"last_login"`
+}
+
+func newUserInfo(user *userdb.User) *userInfo {
+	info := &userInfo{
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		Roles:     user.Roles,
+		Groups:    user.Groups,
+		TwoFactor: user.TOTPEnabled(),
+		Disabled:  user.Disabled,
+		Keys:      len(user.Keys),
+	}
+	if info.Roles == nil {
+		info.Roles = []string{}
+	}
+	if info.Groups == nil {
+		info.Groups = []string{}
+	}
+	return info
+}
+
+// ListUsers lists all users, as a JSON array if asJSON is set
+func ListUsers(dbPath string, asJSON bool) {
+	db, err := userdb.NewStore(dbPath).Load()
 	if err != nil {
 		log.Fatalf("Error: failed to read db %v: %v\n", dbPath, err)
 	}
 
+	if asJSON {
+		infos := []*userInfo{}
+		for i := range db.Users {
+			infos = append(infos, newUserInfo(&db.Users[i]))
+		}
+		printJSON(infos)
+		return
+	}
+
 	if len(db.Users) == 0 {
 		log.Printf("No users found in %v\n", dbPath)
 	} else {
 		for _, user := range db.Users {
-			log.Printf("%v: %v <%v>\n", user.ID, user.Name, user.Email)
+			status := ""
+			if user.Disabled {
+				status = " (disabled)"
+			}
+			log.Printf("%v: %v <%v>%v roles: %v groups: %v\n", user.ID, user.Name, user.Email, status, strings.Join(user.Roles, ","), strings.Join(user.Groups, ","))
 		}
 	}
 }
 
-// AddUser ads a new user
-func AddUser(dbPath, email, name, pw string) {
-	var err error
-
-	db, err := userdb.Read(dbPath)
+// ShowUser prints the details of a user
+func ShowUser(dbPath, email string, asJSON bool) {
+	store := userdb.NewStore(dbPath)
+	user, err := store.LookupByEmail(email)
 	if err != nil {
 		log.Fatalf("Error: failed to read db %v: %v\n", dbPath, err)
 	}
+	if user == nil {
+		log.Fatalf("Error: No user with email %v\n", email)
+	}
+
+	last, err := store.LastLogin(user.ID)
+	if err != nil {
+		log.Fatalf("Error: failed to read the last login of %v: %v\n", email, err)
+	}
+
+	info := &userDetails{userInfo: newUserInfo(user), LastLogin: last}
+	if asJSON {
+		printJSON(info)
+		return
+	}
+
+	lastLogin := "never"
+	if info.LastLogin != nil {
+		lastLogin = info.LastLogin.Format(time.RFC3339)
+	}
+	fmt.Printf("ID:         %v\n", info.ID)
+	fmt.Printf("Name:       %v\n", info.Name)
+	fmt.Printf("Email:      %v\n", info.Email)
+	fmt.Printf("Roles:      %v\n", strings.Join(info.Roles, ", "))
+	fmt.Printf("Groups:     %v\n", strings.Join(info.Groups, ", "))
+	fmt.Printf("Two factor: %v\n", info.TwoFactor)
+	fmt.Printf("Disabled:   %v\n", info.Disabled)
+	fmt.Printf("API keys:   %v\n", info.Keys)
+	fmt.Printf("Last login: %v\n", lastLogin)
+}
+
+func printJSON(obj interface{}) {
+	data, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		log.Fatalf("Error: Could not encode JSON: %v\n", err)
+	}
+	fmt.Println(string(data))
+}
+
+// AddUser ads a new user
+func AddUser(dbPath, email, name, pw string) {
+	var err error
 
 	if email == "" {
 		email, err = promptString("Email")
@@ -68,12 +164,12 @@ func AddUser(dbPath, email, name, pw string) {
 		}
 	}
 
-	if _, err := db.Add(email, name, pw); err != nil {
+	user, err := userdb.NewUser(email, name, pw)
+	if err != nil {
 		log.Fatalf("Error: Could not add user: %v: %v", email, err)
 	}
-
-	if err := db.Write(); err != nil {
-		log.Fatalf("Error: Could not write db %v: %v", dbPath, err)
+	if err := userdb.NewStore(dbPath).AddUser(user); err != nil {
+		log.Fatalf("Error: Could not add user: %v: %v", email, err)
 	}
 
 	log.Printf("User %v added", email)
@@ -81,10 +177,7 @@ func AddUser(dbPath, email, name, pw string) {
 
 // DeleteUser deletes an existing user
 func DeleteUser(dbPath, email string) {
-	db, err := userdb.Read(dbPath)
-	if err != nil {
-		log.Fatalf("Error: failed to read db %v: %v\n", dbPath, err)
-	}
+	var err error
 
 	if email == "" {
 		email, err = promptString("Email")
@@ -93,11 +186,213 @@ func DeleteUser(dbPath, email string) {
 		}
 	}
 
-	db.Delete(email)
-
-	if err := db.Write(); err != nil {
-		log.Fatalf("Error: Could not write db %v: %v", dbPath, err)
+	if err := userdb.NewStore(dbPath).DeleteUser(email); err != nil {
+		log.Fatalf("Error: Could not delete user: %v", err)
 	}
 
 	log.Printf("User %v deleted", email)
 }
+
+// GrantRole grants a role or a group to an existing user
+func GrantRole(dbPath, email, name string, group bool) {
+	store := userdb.NewStore(dbPath)
+	roles, err := store.LoadRoles()
+	if err != nil {
+		log.Fatalf("Error: failed to read db %v: %v\n", dbPath, err)
+	}
+
+	err = store.UpdateUser(email, func(user *userdb.User) error {
+		return roles.Grant(user, name, group)
+	})
+	if err != nil {
+		log.Fatalf("Error: Could not grant %v to %v: %v", name, email, err)
+	}
+
+	log.Printf("Granted %v to %v", name, email)
+}
+
+// RevokeRole revokes a role or a group from an existing user
+func RevokeRole(dbPath, email, name string, group bool) {
+	store := userdb.NewStore(dbPath)
+	roles, err := store.LoadRoles()
+	if err != nil {
+		log.Fatalf("Error: failed to read db %v: %v\n", dbPath, err)
+	}
+
+	err = store.UpdateUser(email, func(user *userdb.User) error {
+		return roles.Revoke(user, name, group)
+	})
+	if err != nil {
+		log.Fatalf("Error: Could not revoke %v from %v: %v", name, email, err)
+	}
+
+	log.Printf("Revoked %v from %v", name, email)
+}
+
+// SetPassword changes the password of a user
+func SetPassword(dbPath, email, pw string) {
+	var err error
+
+	if pw == "" {
+		pw, err = promptPassword()
+		if err != nil {
+			panic(err)
+		}
+	}
+	if pw == "" {
+		log.Fatalf("Error: The password can't be empty\n")
+	}
+
+	err = userdb.NewStore(dbPath).UpdateUser(email, func(user *userdb.User) error {
+		return user.SetPassword(pw)
+	})
+	if err != nil {
+		log.Fatalf("Error: Could not set password: %v", err)
+	}
+
+	log.Printf("Password of %v changed", email)
+}
+
+// UpdateUser changes the name and/or email of a user
+func UpdateUser(dbPath, email, name, newEmail string) {
+	if name == "" && newEmail == "" {
+		log.Fatalf("Error: Nothing to update, use --name or --email\n")
+	}
+
+	err := userdb.NewStore(dbPath).UpdateUser(email, func(user *userdb.User) error {
+		if name != "" {
+			user.Name = name
+		}
+		if newEmail != "" {
+			user.Email = newEmail
+			email = newEmail
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Error: Could not update user: %v", err)
+	}
+
+	log.Printf("User %v updated", email)
+}
+
+// SetDisabled disables or enables a user. Disabled users can't log in and
+// their tokens and API keys stop working.
+func SetDisabled(dbPath, email string, disabled bool) {
+	err := userdb.NewStore(dbPath).UpdateUser(email, func(user *userdb.User) error {
+		user.Disabled = disabled
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Error: Could not update user: %v", err)
+	}
+
+	if disabled {
+		log.Printf("User %v disabled", email)
+	} else {
+		log.Printf("User %v enabled", email)
+	}
+}
+
+// MigrateUsers copies all users, roles and groups to another store. The
+// target must not have any users yet.
+func MigrateUsers(dbPath, to string) {
+	if to == dbPath {
+		log.Fatalf("Error: %v is already the user db\n", to)
+	}
+
+	db, err := userdb.NewStore(dbPath).Load()
+	if err != nil {
+		log.Fatalf("Error: failed to read db %v: %v\n", dbPath, err)
+	}
+
+	err = userdb.NewStore(to).Update(func(target *userdb.UserDB) error {
+		if len(target.Users) > 0 {
+			return fmt.Errorf("%v already has users", to)
+		}
+		target.Roles = db.Roles
+		target.Groups = db.Groups
+		target.Users = db.Users
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Error: Could not migrate to %v: %v", to, err)
+	}
+
+	log.Printf("Migrated %v users from %v to %v, use --db=%v to use the new store", len(db.Users), dbPath, to, to)
+}
+
+// EnableTOTP enrolls a user in two factor authentication and prints the
+// otpauth URI for their authenticator app
+func EnableTOTP(dbPath, email string) {
+	var uri string
+	err := userdb.NewStore(dbPath).UpdateUser(email, func(user *userdb.User) error {
+		var err error
+		uri, err = user.EnableTOTP("netlify-git-api")
+		return err
+	})
+	if err != nil {
+		log.Fatalf("Error: Could not enable two factor authentication for %v: %v", email, err)
+	}
+
+	log.Printf("Two factor authentication enabled for %v, add this URI to an authenticator app:", email)
+	fmt.Println(uri)
+}
+
+// DisableTOTP removes two factor authentication from a user
+func DisableTOTP(dbPath, email string) {
+	err := userdb.NewStore(dbPath).UpdateUser(email, func(user *userdb.User) error {
+		user.DisableTOTP()
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Error: Could not disable two factor authentication for %v: %v", email, err)
+	}
+
+	log.Printf("Two factor authentication disabled for %v", email)
+}
+
+// ListLocked lists the emails and IPs locked out after failed logins
+func ListLocked(configPath string) {
+	config, err := ReadConfig(configPath)
+	if err != nil {
+		log.Fatalf("Error: failed to read config %v: %v\n", configPath, err)
+	}
+
+	locked, err := lockout.New(&config.Lockout).Locked()
+	if err != nil {
+		log.Fatalf("Error: failed to read lockout state %v: %v\n", config.Lockout.Path, err)
+	}
+
+	if len(locked) == 0 {
+		log.Printf("No locked out users or IPs\n")
+	}
+	for key, entry := range locked {
+		log.Printf("%v: %v failures, locked until %v\n", key, entry.Failures, entry.BlockedUntil.Format(time.RFC3339))
+	}
+}
+
+// UnlockUser clears the failed logins of an email and optionally an IP
+func UnlockUser(configPath, email, ip string) {
+	if email == "" && ip == "" {
+		log.Fatalf("Error: an email or an IP to unlock is required\n")
+	}
+
+	config, err := ReadConfig(configPath)
+	if err != nil {
+		log.Fatalf("Error: failed to read config %v: %v\n", configPath, err)
+	}
+
+	var keys []string
+	if email != "" {
+		keys = append(keys, lockout.EmailKey(email))
+	}
+	if ip != "" {
+		keys = append(keys, lockout.IPKey(ip))
+	}
+
+	if err := lockout.New(&config.Lockout).Reset(keys...); err != nil {
+		log.Fatalf("Error: failed to update lockout state %v: %v\n", config.Lockout.Path, err)
+	}
+	log.Printf("Unlocked %v\n", strings.Join(keys, ", "))
+}
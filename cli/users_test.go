@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/netlify/netlify-git-api/auth"
+	"github.com/netlify/netlify-git-api/tokenstore"
+	"github.com/netlify/netlify-git-api/userdb"
+)
+
+func TestResolverAuthenticate(t *testing.T) {
+	db := &userdb.UserDB{}
+	if _, err := db.Add("active@example.com", "Active", "secret"); err != nil {
+		t.Fatalf("Error adding user: %v", err)
+	}
+	disabled, err := db.Add("disabled@example.com", "Disabled", "secret")
+	if err != nil {
+		t.Fatalf("Error adding user: %v", err)
+	}
+	disabled.Disabled = true
+
+	store := userdb.NewMemoryStore(db)
+	users, err := userdb.NewLive(store)
+	if err != nil {
+		t.Fatalf("Error loading store: %v", err)
+	}
+	authenticator := auth.NewUserDB(store)
+	r := &resolver{
+		store:  store,
+		users:  users,
+		auth:   authenticator,
+		tokens: &storeTokens{store: tokenstore.NewMemoryStore(), accessTTL: time.Hour, refreshTTL: time.Hour, auth: authenticator},
+		totp:   userdb.NewTOTPGuard(),
+	}
+
+	tests := []struct {
+		email    string
+		password string
+		token    bool
+	}{
+		{email: "active@example.com", password: "secret", token: true},
+		{email: "active@example.com", password: "wrong"},
+		{email: "disabled@example.com", password: "secret"},
+		{email: "nobody@example.com", password: "secret"},
+	}
+
+	for _, test := range tests {
+		token, err := r.Authenticate(test.email, test.password, "")
+		if err != nil {
+			t.Errorf("Authenticate(%q, %q): unexpected error %v", test.email, test.password, err)
+			continue
+		}
+		if (token != nil) != test.token {
+			t.Errorf("Authenticate(%q, %q): expected a token %v, got %v", test.email, test.password, test.token, token)
+		}
+	}
+
+	logins := map[string]bool{"active@example.com": true, "disabled@example.com": false}
+	for email, recorded := range logins {
+		user, _ := store.LookupByEmail(email)
+		last, err := store.LastLogin(user.ID)
+		if err != nil {
+			t.Fatalf("Error reading the last login: %v", err)
+		}
+		if (last != nil) != recorded {
+			t.Errorf("%v: expected a recorded login %v, got %v", email, recorded, last)
+		}
+	}
+}
+
+func TestUserDetailsJSON(t *testing.T) {
+	lastLogin := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	user := &userdb.User{ID: "user-1", Email: "test@example.com", Keys: []userdb.APIKey{{}}}
+
+	tests := []struct {
+		info     interface{}
+		expected string
+	}{
+		{
+			info:     newUserInfo(user),
+			expected: `{"id":"user-1","name":"","email":"test@example.com","roles":[],"groups":[],"two_factor":false,"disabled":false,"keys":1}`,
+		},
+		{
+			info:     &userDetails{userInfo: newUserInfo(user)},
+			expected: `{"id":"user-1","name":"","email":"test@example.com","roles":[],"groups":[],"two_factor":false,"disabled":false,"keys":1,"last_login":null}`,
+		},
+		{
+			info:     &userDetails{userInfo: newUserInfo(user), LastLogin: &lastLogin},
+			expected: `{"id":"user-1","name":"","email":"test@example.com","roles":[],"groups":[],"two_factor":false,"disabled":false,"keys":1,"last_login":"2030-01-02T03:04:05Z"}`,
+		},
+	}
+
+	for _, test := range tests {
+		data, err := json.Marshal(test.info)
+		if err != nil {
+			t.Errorf("Error encoding %v: %v", test.info, err)
+			continue
+		}
+		if string(data) != test.expected {
+			t.Errorf("Expected %v, got %v", test.expected, string(data))
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package jwt
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndParse(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Now()
+	claims := &Claims{
+		Subject:   "user-1",
+		Type:      "access",
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Hour).Unix(),
+	}
+	token, err := Sign(claims, secret)
+	if err != nil {
+		t.Fatalf("Error signing token: %v", err)
+	}
+
+	expired, err := Sign(&Claims{Subject: "user-1", IssuedAt: now.Add(-time.Hour).Unix(), ExpiresAt: now.Add(-time.Second).Unix()}, secret)
+	if err != nil {
+		t.Fatalf("Error signing token: %v", err)
+	}
+
+	parts := strings.Split(token, ".")
+	otherPayload := encode([]byte(`{"sub":"admin","typ":"access","exp":9999999999}`))
+	otherHeader := encode([]byte(`{"alg":"none","typ":"JWT"}`))
+
+	tests := []struct {
+		name   string
+		token  string
+		secret []byte
+		err    error
+	}{
+		{name: "valid", token: token, secret: secret},
+		{name: "wrong secret", token: token, secret: []byte("other"), err: ErrInvalidToken},
+		{name: "expired", token: expired, secret: secret, err: ErrExpiredToken},
+		{name: "changed payload", token: parts[0] + "." + otherPayload + "." + parts[2], secret: secret, err: ErrInvalidToken},
+		{name: "other algorithm", token: otherHeader + "." + parts[1] + ".", secret: secret, err: ErrInvalidToken},
+		{name: "bad signature encoding", token: parts[0] + "." + parts[1] + ".!!", secret: secret, err: ErrInvalidToken},
+		{name: "missing signature", token: parts[0] + "." + parts[1], secret: secret, err: ErrInvalidToken},
+		{name: "empty", token: "", secret: secret, err: ErrInvalidToken},
+	}
+
+	for _, test := range tests {
+		parsed, err := Parse(test.token, test.secret)
+		if err != test.err {
+			t.Errorf("%v: expected error %v, got %v", test.name, test.err, err)
+			continue
+		}
+		if test.err == nil && !reflect.DeepEqual(parsed, claims) {
+			t.Errorf("%v: expected %+v, got %+v", test.name, claims, parsed)
+		}
+	}
+}
+
+func TestParseInvalidPayload(t *testing.T) {
+	secret := []byte("secret")
+	unsigned := header + "." + encode([]byte("not json"))
+	token := unsigned + "." + encode(sign(unsigned, secret))
+
+	if _, err := Parse(token, secret); err != ErrInvalidToken {
+		t.Errorf("Expected %v for a payload that isn't JSON, got %v", ErrInvalidToken, err)
+	}
+}
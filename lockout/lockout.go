@@ -0,0 +1,204 @@
+package lockout
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Config configures the backoff and lockout of failed attempts. The first
+// DelayAfter failures of a key don't delay the next attempt. After that each
+// failure makes the key wait BaseDelay, doubled for every further failure (up
+// to MaxDelay), and after MaxFailures it is locked out for Duration.
+type Config struct {
+	Path        string        `yaml:"path"`
+	DelayAfter  int           `yaml:"delay_after"`
+	MaxFailures int           `yaml:"max_failures"`
+	BaseDelay   time.Duration `yaml:"base_delay"`
+	MaxDelay    time.Duration `yaml:"max_delay"`
+	Duration    time.Duration `yaml:"duration"`
+}
+
+// Entry is the failure state of a single key
+type Entry struct {
+	Failures     int       `json:"failures"`
+	LastFailure  time.Time `json:"last_failure"`
+	BlockedUntil time.Time `json:"blocked_until"`
+}
+
+// Tracker tracks failed attempts per key (ie. an email or an IP). The state
+// is kept in a file that is reread on every call so it survives restarts and
+// can be changed by other processes.
+type Tracker struct {
+	mutex  sync.Mutex
+	config *Config
+}
+
+// New creates a tracker
+func New(config *Config) *Tracker {
+	return &Tracker{config: config}
+}
+
+// EmailKey is the key for attempts on an email
+func EmailKey(email string) string {
+	return "email:" + email
+}
+
+// IPKey is the key for attempts from an IP
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check returns how long the caller has to wait before the next attempt for
+// any of the keys, or 0 if attempts are allowed
+func (t *Tracker) Check(keys ...string) (time.Duration, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	entries, err := t.read()
+	if err != nil {
+		return 0, err
+	}
+
+	var wait time.Duration
+	now := time.Now()
+	for _, key := range keys {
+		if entry, ok := entries[key]; ok && entry.BlockedUntil.After(now) {
+			if d := entry.BlockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait, nil
+}
+
+// Fail records a failed attempt for the keys
+func (t *Tracker) Fail(keys ...string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	entries, err := t.read()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, key := range keys {
+		entry, ok := entries[key]
+		if !ok {
+			entry = &Entry{}
+			entries[key] = entry
+		}
+		entry.Failures++
+		entry.LastFailure = now
+
+		if entry.Failures >= t.config.MaxFailures {
+			entry.BlockedUntil = now.Add(t.config.Duration)
+			continue
+		}
+		if entry.Failures <= t.config.DelayAfter {
+			continue
+		}
+
+		delay := t.config.BaseDelay
+		for i := t.config.DelayAfter + 1; i < entry.Failures && delay < t.config.MaxDelay; i++ {
+			delay *= 2
+		}
+		if delay > t.config.MaxDelay {
+			delay = t.config.MaxDelay
+		}
+		entry.BlockedUntil = now.Add(delay)
+	}
+
+	return t.write(entries)
+}
+
+// Reset clears the failures of the keys, ie. after a successful attempt
+func (t *Tracker) Reset(keys ...string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	entries, err := t.read()
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for _, key := range keys {
+		if _, ok := entries[key]; ok {
+			delete(entries, key)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return t.write(entries)
+}
+
+// Locked returns the keys that are currently blocked
+func (t *Tracker) Locked() (map[string]*Entry, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	entries, err := t.read()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for key, entry := range entries {
+		if !entry.BlockedUntil.After(now) {
+			delete(entries, key)
+		}
+	}
+	return entries, nil
+}
+
+func (t *Tracker) read() (map[string]*Entry, error) {
+	entries := map[string]*Entry{}
+	data, err := ioutil.ReadFile(t.config.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, nil
+		}
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &entries)
+	return entries, err
+}
+
+// write replaces the state file, dropping entries that expired and haven't
+// failed for long enough that they no longer affect the backoff
+func (t *Tracker) write(entries map[string]*Entry) error {
+	cutoff := time.Now().Add(-t.config.Duration)
+	for key, entry := range entries {
+		if entry.BlockedUntil.Before(cutoff) && entry.LastFailure.Before(cutoff) {
+			delete(entries, key)
+		}
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(t.config.Path), filepath.Base(t.config.Path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), t.config.Path)
+}
@@ -0,0 +1,120 @@
+package lockout
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestTracker(t *testing.T) (*Tracker, func()) {
+	dir, err := ioutil.TempDir("", "lockout-test")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	tracker := New(&Config{
+		Path:        filepath.Join(dir, "lockout.json"),
+		DelayAfter:  2,
+		MaxFailures: 6,
+		BaseDelay:   time.Minute,
+		MaxDelay:    3 * time.Minute,
+		Duration:    time.Hour,
+	})
+	return tracker, func() { os.RemoveAll(dir) }
+}
+
+func TestFailBackoff(t *testing.T) {
+	tracker, cleanup := newTestTracker(t)
+	defer cleanup()
+
+	// The wait after each consecutive failure
+	expected := []time.Duration{0, 0, time.Minute, 2 * time.Minute, 3 * time.Minute, time.Hour}
+	for i, delay := range expected {
+		if err := tracker.Fail(EmailKey("test@example.com")); err != nil {
+			t.Fatalf("Error recording failure %v: %v", i+1, err)
+		}
+		wait, err := tracker.Check(EmailKey("test@example.com"))
+		if err != nil {
+			t.Fatalf("Error checking after failure %v: %v", i+1, err)
+		}
+		if wait > delay || wait < delay-time.Second {
+			t.Errorf("After %v failures: expected to wait %v, got %v", i+1, delay, wait)
+		}
+	}
+}
+
+func TestCheckAndReset(t *testing.T) {
+	tracker, cleanup := newTestTracker(t)
+	defer cleanup()
+
+	email := EmailKey("test@example.com")
+	ip := IPKey("10.0.0.1")
+	for i := 0; i < 3; i++ {
+		if err := tracker.Fail(email, ip); err != nil {
+			t.Fatalf("Error recording failure: %v", err)
+		}
+	}
+
+	tests := []struct {
+		keys    []string
+		blocked bool
+	}{
+		{keys: []string{email}, blocked: true},
+		{keys: []string{ip}, blocked: true},
+		{keys: []string{EmailKey("other@example.com"), ip}, blocked: true},
+		{keys: []string{EmailKey("other@example.com"), IPKey("10.0.0.2")}, blocked: false},
+	}
+	for _, test := range tests {
+		wait, err := tracker.Check(test.keys...)
+		if err != nil {
+			t.Fatalf("Error checking %v: %v", test.keys, err)
+		}
+		if (wait > 0) != test.blocked {
+			t.Errorf("Check(%v): expected blocked %v, got a wait of %v", test.keys, test.blocked, wait)
+		}
+	}
+
+	locked, err := tracker.Locked()
+	if err != nil {
+		t.Fatalf("Error listing locked keys: %v", err)
+	}
+	if len(locked) != 2 || locked[email] == nil || locked[email].Failures != 3 {
+		t.Errorf("Expected the email and the IP to be locked, got %v", locked)
+	}
+
+	if err := tracker.Reset(email); err != nil {
+		t.Fatalf("Error resetting: %v", err)
+	}
+	if wait, _ := tracker.Check(email); wait != 0 {
+		t.Errorf("Expected the email to be unlocked, got a wait of %v", wait)
+	}
+	if wait, _ := tracker.Check(ip); wait == 0 {
+		t.Errorf("Expected the IP to stay locked")
+	}
+
+	// The state is shared through the file
+	other := New(tracker.config)
+	if wait, _ := other.Check(ip); wait == 0 {
+		t.Errorf("Expected another tracker to see the locked IP")
+	}
+}
+
+func TestFailuresBelowThresholdAreKept(t *testing.T) {
+	tracker, cleanup := newTestTracker(t)
+	defer cleanup()
+
+	key := EmailKey("test@example.com")
+	tracker.Fail(key)
+	tracker.Fail(key)
+	// Writing for another key must not drop the failures without a delay
+	tracker.Fail(IPKey("10.0.0.1"))
+
+	entries, err := tracker.read()
+	if err != nil {
+		t.Fatalf("Error reading state: %v", err)
+	}
+	if entries[key] == nil || entries[key].Failures != 2 {
+		t.Errorf("Expected 2 failures for %v, got %v", key, entries[key])
+	}
+}
@@ -55,7 +55,7 @@ func (b *Blob) Read(p []byte) (n int, err error) {
 
 // GetBlob returns a single blob from the repo
 func (r *Repo) GetBlob(sha string) (*Blob, error) {
-	oid, err := git.NewOid(sha)
+	oid, err := parseOid(sha)
 	if err != nil {
 		return nil, err
 	}
@@ -1,6 +1,7 @@
 package repo
 
 import (
+	"strings"
 	"time"
 
 	"gopkg.in/libgit2/git2go.v22"
@@ -30,6 +31,15 @@ type Author struct {
 	Date  time.Time `json:"date"`
 }
 
+// CommitFilter limits the commits returned when listing history.
+// Zero values don't filter.
+type CommitFilter struct {
+	Path   string
+	Author string
+	Since  time.Time
+	Until  time.Time
+}
+
 // FileChange represents a file that will change between two commits
 // Action can be "create", "update", "delete"
 type FileChange struct {
@@ -39,7 +49,7 @@ type FileChange struct {
 
 // GetCommit looks up a commit from a sha
 func (r *Repo) GetCommit(sha string) (*Commit, error) {
-	oid, err := git.NewOid(sha)
+	oid, err := parseOid(sha)
 	if err != nil {
 		return nil, err
 	}
@@ -78,7 +88,7 @@ func (r *Repo) GetCommit(sha string) (*Commit, error) {
 
 // CreateCommit creates a new commit in the repository
 func (r *Repo) CreateCommit(treeSha, msg string, parentShas []string) (*Commit, error) {
-	treeID, err := git.NewOid(treeSha)
+	treeID, err := parseOid(treeSha)
 	if err != nil {
 		return nil, err
 	}
@@ -90,24 +100,19 @@ func (r *Repo) CreateCommit(treeSha, msg string, parentShas []string) (*Commit,
 
 	parents := make([]*git.Commit, len(parentShas))
 	for i := 0; i < len(parents); i++ {
-		oid, err := git.NewOid(parentShas[i])
+		oid, err := parseOid(parentShas[i])
 		if err != nil {
 			return nil, err
 		}
 
 		commit, err := r.repo.LookupCommit(oid)
 		if err != nil {
-			return nil, err
+			return nil, &NotFoundError{id: parentShas[i], object: "Parent Commit"}
 		}
 		parents[i] = commit
 	}
 
-	sig := &git.Signature{
-		Name:  r.user.Name(),
-		Email: r.user.Email(),
-		When:  time.Now(),
-	}
-
+	sig := r.signature()
 	oid, err := r.repo.CreateCommit("", sig, sig, msg, tree, parents...)
 	if err != nil {
 		return nil, err
@@ -116,6 +121,146 @@ func (r *Repo) CreateCommit(treeSha, msg string, parentShas []string) (*Commit,
 	return r.GetCommit(oid.String())
 }
 
+// ListCommits walks the history starting at rev (a branch, tag or sha, empty
+// for HEAD) with the most recent commits first. Skips the first offset matching
+// commits and returns at most limit commits.
+func (r *Repo) ListCommits(rev string, filter *CommitFilter, offset, limit int) ([]*Commit, error) {
+	start, err := r.lookupRevision(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	walk, err := r.repo.Walk()
+	if err != nil {
+		return nil, err
+	}
+	defer walk.Free()
+
+	walk.Sorting(git.SortTime)
+	if err := walk.Push(start.Id()); err != nil {
+		return nil, err
+	}
+
+	commits := []*Commit{}
+	oid := new(git.Oid)
+	for len(commits) < limit {
+		err := walk.Next(oid)
+		if git.IsErrorCode(err, git.ErrIterOver) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		commit, err := r.repo.LookupCommit(oid)
+		if err != nil {
+			return nil, err
+		}
+
+		ok, err := r.matchCommit(commit, filter)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		if offset > 0 {
+			offset--
+			continue
+		}
+
+		repoCommit, err := r.GetCommit(oid.String())
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, repoCommit)
+	}
+
+	return commits, nil
+}
+
+func (r *Repo) matchCommit(commit *git.Commit, filter *CommitFilter) (bool, error) {
+	if filter == nil {
+		return true, nil
+	}
+
+	author := commit.Author()
+	if filter.Author != "" {
+		if author == nil {
+			return false, nil
+		}
+		if !strings.EqualFold(author.Email, filter.Author) && !strings.EqualFold(author.Name, filter.Author) {
+			return false, nil
+		}
+	}
+
+	if !filter.Since.IsZero() || !filter.Until.IsZero() {
+		if author == nil {
+			return false, nil
+		}
+		if !filter.Since.IsZero() && author.When.Before(filter.Since) {
+			return false, nil
+		}
+		if !filter.Until.IsZero() && author.When.After(filter.Until) {
+			return false, nil
+		}
+	}
+
+	if filter.Path != "" {
+		return r.touchesPath(commit, filter.Path)
+	}
+
+	return true, nil
+}
+
+// touchesPath checks if a commit changed the file or directory at pathname.
+// Like git log, a merge only counts if it differs from all of its parents.
+func (r *Repo) touchesPath(commit *git.Commit, pathname string) (bool, error) {
+	id, err := pathID(commit, pathname)
+	if err != nil {
+		return false, err
+	}
+
+	if commit.ParentCount() == 0 {
+		return id != nil, nil
+	}
+
+	var i uint
+	for i = 0; i < commit.ParentCount(); i++ {
+		parent, err := r.repo.LookupCommit(commit.ParentId(i))
+		if err != nil {
+			return false, err
+		}
+		parentID, err := pathID(parent, pathname)
+		if err != nil {
+			return false, err
+		}
+		if id == nil && parentID == nil {
+			return false, nil
+		}
+		if id != nil && parentID != nil && id.Equal(parentID) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// pathID returns the id of the object at pathname in a commit or nil
+func pathID(commit *git.Commit, pathname string) (*git.Oid, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := tree.EntryByPath(pathname)
+	if err != nil {
+		return nil, nil
+	}
+	return entry.Id, nil
+}
+
 // ChangedFiles between two commits
 func (c *Commit) ChangedFiles(other *Commit) ([]*FileChange, error) {
 	oldTree, err := c.repo.repo.LookupTree(c.Tree.id)
@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestListCommits(t *testing.T) {
+	r, cleanup := newTestRepo(t, map[string]string{"README.md": "v1"})
+	defer cleanup()
+
+	c1 := testRefSha(t, r, "refs/heads/master")
+	c2 := testCommit(t, r, c1, map[string]string{"content/a.md": "a"}).Sha
+	r.user = &testUser{name: "Other User", email: "other@example.com"}
+	c3 := testCommit(t, r, c2, map[string]string{"README.md": "v2"}).Sha
+	r.user = newTestUser()
+	c4 := testCommit(t, r, c3, map[string]string{"content/b.md": "b"}).Sha
+	testSetRef(t, r, "refs/heads/master", c4)
+
+	tests := []struct {
+		rev     string
+		filter  *CommitFilter
+		offset  int
+		limit   int
+		commits []string
+	}{
+		{rev: "", limit: 10, commits: []string{c4, c3, c2, c1}},
+		{rev: "master", limit: 2, commits: []string{c4, c3}},
+		{rev: "master", offset: 2, limit: 2, commits: []string{c2, c1}},
+		{rev: "master", offset: 4, limit: 2, commits: []string{}},
+		{rev: c2, limit: 10, commits: []string{c2, c1}},
+		{rev: "master", filter: &CommitFilter{Path: "content"}, limit: 10, commits: []string{c4, c2}},
+		{rev: "master", filter: &CommitFilter{Path: "README.md"}, limit: 10, commits: []string{c3, c1}},
+		{rev: "master", filter: &CommitFilter{Path: "README.md"}, offset: 1, limit: 10, commits: []string{c1}},
+		{rev: "master", filter: &CommitFilter{Author: "other@example.com"}, limit: 10, commits: []string{c3}},
+		{rev: "master", filter: &CommitFilter{Author: "other user"}, limit: 10, commits: []string{c3}},
+		{rev: "master", filter: &CommitFilter{Since: time.Now().Add(time.Hour)}, limit: 10, commits: []string{}},
+		{rev: "master", filter: &CommitFilter{Until: time.Now().Add(-time.Hour)}, limit: 10, commits: []string{}},
+		{rev: "master", filter: &CommitFilter{Since: time.Now().Add(-time.Hour), Until: time.Now().Add(time.Hour)}, limit: 1, commits: []string{c4}},
+	}
+
+	for i, test := range tests {
+		commits, err := r.ListCommits(test.rev, test.filter, test.offset, test.limit)
+		if err != nil {
+			t.Errorf("Test %v: unexpected error %v", i, err)
+			continue
+		}
+		shas := []string{}
+		for _, commit := range commits {
+			shas = append(shas, commit.Sha)
+		}
+		if !reflect.DeepEqual(shas, test.commits) {
+			t.Errorf("Test %v: expected %v, got %v", i, test.commits, shas)
+		}
+	}
+
+	if _, err := r.ListCommits("nope", nil, 0, 10); !sameErrorType(err, &NotFoundError{}) {
+		t.Errorf("Expected a NotFoundError for an unknown revision, got %v", err)
+	}
+}
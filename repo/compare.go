@@ -0,0 +1,246 @@
+package repo
+
+import (
+	"strings"
+
+	"gopkg.in/libgit2/git2go.v22"
+)
+
+const maxCompareCommits = 250
+
+// Comparison describes the difference between a base and a head commit
+// Status can be "identical", "ahead", "behind" or "diverged"
+type Comparison struct {
+	BaseCommit      *Commit     `json:"base_commit"`
+	MergeBaseCommit *Commit     `json:"merge_base_commit"`
+	Status          string      `json:"status"`
+	AheadBy         int         `json:"ahead_by"`
+	BehindBy        int         `json:"behind_by"`
+	TotalCommits    int         `json:"total_commits"`
+	Commits         []*Commit   `json:"commits"`
+	Files           []*FileDiff `json:"files"`
+}
+
+// FileDiff is a single changed file in a comparison
+// Status can be "added", "removed", "modified", "renamed", "copied" or "changed"
+type FileDiff struct {
+	Filename         string `json:"filename"`
+	PreviousFilename string `json:"previous_filename,omitempty"`
+	Status           string `json:"status"`
+	Sha              string `json:"sha"`
+	Additions        int    `json:"additions"`
+	Deletions        int    `json:"deletions"`
+	Changes          int    `json:"changes"`
+	Patch            string `json:"patch,omitempty"`
+}
+
+// Compare two revisions (branches, tags or shas). The commits are the ones
+// reachable from head but not from base, oldest first. The files are diffed
+// between the merge base and head, with unified patches if withPatch is set.
+func (r *Repo) Compare(base, head string, withPatch bool) (*Comparison, error) {
+	baseCommit, err := r.lookupRevision(base)
+	if err != nil {
+		return nil, err
+	}
+	headCommit, err := r.lookupRevision(head)
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := &Comparison{}
+	comparison.BaseCommit, err = r.GetCommit(baseCommit.Id().String())
+	if err != nil {
+		return nil, err
+	}
+
+	comparison.AheadBy, comparison.BehindBy, err = r.repo.AheadBehind(headCommit.Id(), baseCommit.Id())
+	if err != nil {
+		return nil, err
+	}
+	comparison.TotalCommits = comparison.AheadBy
+	switch {
+	case comparison.AheadBy == 0 && comparison.BehindBy == 0:
+		comparison.Status = "identical"
+	case comparison.BehindBy == 0:
+		comparison.Status = "ahead"
+	case comparison.AheadBy == 0:
+		comparison.Status = "behind"
+	default:
+		comparison.Status = "diverged"
+	}
+
+	oldTree, err := baseCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	// Unrelated histories have no merge base, diff against base instead
+	mergeBase, err := r.repo.MergeBase(baseCommit.Id(), headCommit.Id())
+	if err != nil && !git.IsErrorCode(err, git.ErrNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		comparison.MergeBaseCommit, err = r.GetCommit(mergeBase.String())
+		if err != nil {
+			return nil, err
+		}
+		oldTree, err = r.repo.LookupTree(comparison.MergeBaseCommit.Tree.id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	comparison.Commits, err = r.commitsBetween(baseCommit.Id(), headCommit.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	newTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	comparison.Files, err = r.diffFiles(oldTree, newTree, withPatch)
+	if err != nil {
+		return nil, err
+	}
+
+	return comparison, nil
+}
+
+// commitsBetween lists the commits reachable from head but not from base
+func (r *Repo) commitsBetween(base, head *git.Oid) ([]*Commit, error) {
+	walk, err := r.repo.Walk()
+	if err != nil {
+		return nil, err
+	}
+	defer walk.Free()
+
+	walk.Sorting(git.SortTopological | git.SortReverse)
+	if err := walk.Push(head); err != nil {
+		return nil, err
+	}
+	if err := walk.Hide(base); err != nil {
+		return nil, err
+	}
+
+	commits := []*Commit{}
+	oid := new(git.Oid)
+	for len(commits) < maxCompareCommits {
+		err := walk.Next(oid)
+		if git.IsErrorCode(err, git.ErrIterOver) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		commit, err := r.GetCommit(oid.String())
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, commit)
+	}
+
+	return commits, nil
+}
+
+// diffFiles lists the changed files between two trees with renames detected
+func (r *Repo) diffFiles(oldTree, newTree *git.Tree, withPatch bool) ([]*FileDiff, error) {
+	diff, err := r.repo.DiffTreeToTree(oldTree, newTree, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer diff.Free()
+
+	findOpts, err := git.DefaultDiffFindOptions()
+	if err != nil {
+		return nil, err
+	}
+	findOpts.Flags = git.DiffFindRenames
+	if err := diff.FindSimilar(&findOpts); err != nil {
+		return nil, err
+	}
+
+	deltas, err := diff.NumDeltas()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*FileDiff, deltas)
+	for i := 0; i < deltas; i++ {
+		delta, err := diff.GetDelta(i)
+		if err != nil {
+			return nil, err
+		}
+
+		file := &FileDiff{
+			Filename: delta.NewFile.Path,
+			Status:   deltaStatus(delta.Status),
+			Sha:      delta.NewFile.Oid.String(),
+		}
+		switch delta.Status {
+		case git.DeltaDeleted:
+			file.Filename = delta.OldFile.Path
+			file.Sha = delta.OldFile.Oid.String()
+		case git.DeltaRenamed, git.DeltaCopied:
+			file.PreviousFilename = delta.OldFile.Path
+		}
+
+		patch, err := diff.Patch(i)
+		if err != nil {
+			return nil, err
+		}
+		text, err := patch.String()
+		patch.Free()
+		if err != nil {
+			return nil, err
+		}
+
+		file.Additions, file.Deletions = countLines(text)
+		file.Changes = file.Additions + file.Deletions
+		if withPatch {
+			file.Patch = text
+		}
+		files[i] = file
+	}
+
+	return files, nil
+}
+
+func deltaStatus(status git.Delta) string {
+	switch status {
+	case git.DeltaAdded:
+		return "added"
+	case git.DeltaDeleted:
+		return "removed"
+	case git.DeltaRenamed:
+		return "renamed"
+	case git.DeltaCopied:
+		return "copied"
+	case git.DeltaTypeChange:
+		return "changed"
+	default:
+		return "modified"
+	}
+}
+
+// countLines counts the added and deleted lines in the hunks of a patch
+func countLines(patch string) (additions, deletions int) {
+	inHunk := false
+	for _, line := range strings.Split(patch, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			inHunk = true
+			continue
+		}
+		if !inHunk {
+			continue
+		}
+		if strings.HasPrefix(line, "+") {
+			additions++
+		} else if strings.HasPrefix(line, "-") {
+			deletions++
+		}
+	}
+	return
+}
@@ -0,0 +1,115 @@
+package repo
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	r, cleanup := newTestRepo(t, map[string]string{"README.md": "one\ntwo\n", "old.md": "old file\n"})
+	defer cleanup()
+
+	base := testRefSha(t, r, "refs/heads/master")
+	feature := testCommit(t, r, base, map[string]string{"README.md": "one\n2\nthree\n", "new.md": "brand new\n", "old.md": ""}).Sha
+	master := testCommit(t, r, base, map[string]string{"other.md": "other\n"}).Sha
+	orphan := testCommit(t, r, "", map[string]string{"README.md": "orphan\n"}).Sha
+	testSetRef(t, r, "refs/heads/master", master)
+	testSetRef(t, r, "refs/heads/feature", feature)
+
+	tests := []struct {
+		base      string
+		head      string
+		status    string
+		ahead     int
+		behind    int
+		mergeBase string
+		files     []string
+	}{
+		{base: "master", head: "feature", status: "diverged", ahead: 1, behind: 1, mergeBase: base, files: []string{"README.md:modified", "new.md:added", "old.md:removed"}},
+		{base: "feature", head: "feature", status: "identical", mergeBase: feature, files: []string{}},
+		{base: base, head: "feature", status: "ahead", ahead: 1, mergeBase: base, files: []string{"README.md:modified", "new.md:added", "old.md:removed"}},
+		{base: "feature", head: base, status: "behind", behind: 1, mergeBase: base, files: []string{}},
+		{base: "master", head: orphan, status: "diverged", ahead: 1, behind: 2, files: []string{"README.md:modified", "old.md:removed", "other.md:removed"}},
+	}
+
+	for _, test := range tests {
+		comparison, err := r.Compare(test.base, test.head, true)
+		if err != nil {
+			t.Errorf("Compare(%v, %v): unexpected error %v", test.base, test.head, err)
+			continue
+		}
+		if comparison.Status != test.status || comparison.AheadBy != test.ahead || comparison.BehindBy != test.behind {
+			t.Errorf("Compare(%v, %v): expected %v (+%v -%v), got %v (+%v -%v)", test.base, test.head, test.status, test.ahead, test.behind, comparison.Status, comparison.AheadBy, comparison.BehindBy)
+		}
+		if len(comparison.Commits) != test.ahead {
+			t.Errorf("Compare(%v, %v): expected %v commits, got %v", test.base, test.head, test.ahead, len(comparison.Commits))
+		}
+
+		var mergeBase string
+		if comparison.MergeBaseCommit != nil {
+			mergeBase = comparison.MergeBaseCommit.Sha
+		}
+		if mergeBase != test.mergeBase {
+			t.Errorf("Compare(%v, %v): expected merge base %v, got %v", test.base, test.head, test.mergeBase, mergeBase)
+		}
+
+		files := []string{}
+		for _, file := range comparison.Files {
+			files = append(files, file.Filename+":"+file.Status)
+		}
+		sort.Strings(files)
+		if !reflect.DeepEqual(files, test.files) {
+			t.Errorf("Compare(%v, %v): expected files %v, got %v", test.base, test.head, test.files, files)
+		}
+	}
+
+	if _, err := r.Compare("master", "nope", true); !sameErrorType(err, &NotFoundError{}) {
+		t.Errorf("Expected a NotFoundError for an unknown head, got %v", err)
+	}
+}
+
+func TestComparePatches(t *testing.T) {
+	r, cleanup := newTestRepo(t, map[string]string{"README.md": "one\ntwo\n"})
+	defer cleanup()
+
+	base := testRefSha(t, r, "refs/heads/master")
+	head := testCommit(t, r, base, map[string]string{"README.md": "one\n2\nthree\n"}).Sha
+
+	for _, withPatch := range []bool{true, false} {
+		comparison, err := r.Compare(base, head, withPatch)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(comparison.Files) != 1 {
+			t.Fatalf("Expected a single file, got %v", len(comparison.Files))
+		}
+		file := comparison.Files[0]
+		if file.Additions != 2 || file.Deletions != 1 || file.Changes != 3 {
+			t.Errorf("Expected +2 -1, got +%v -%v (%v changes)", file.Additions, file.Deletions, file.Changes)
+		}
+		if withPatch == (file.Patch == "") {
+			t.Errorf("Expected a patch only when asked for, withPatch=%v got %q", withPatch, file.Patch)
+		}
+	}
+}
+
+func TestCountLines(t *testing.T) {
+	tests := []struct {
+		patch     string
+		additions int
+		deletions int
+	}{
+		{patch: "", additions: 0, deletions: 0},
+		{patch: "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1,2 +1,3 @@\n one\n-two\n+2\n+three\n", additions: 2, deletions: 1},
+		{patch: "--- a/f\n+++ b/f\n@@ -1 +0,0 @@\n-gone\n@@ -5 +4 @@\n-x\n+y\n", additions: 1, deletions: 2},
+		{patch: "--- /dev/null\n+++ b/f\n", additions: 0, deletions: 0},
+	}
+
+	for _, test := range tests {
+		additions, deletions := countLines(test.patch)
+		if additions != test.additions || deletions != test.deletions {
+			t.Errorf("countLines(%q): expected +%v -%v, got +%v -%v", test.patch, test.additions, test.deletions, additions, deletions)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package repo
 
 import (
-	"fmt"
 	"path"
 
 	"gopkg.in/libgit2/git2go.v22"
@@ -56,24 +55,20 @@ func (r *Repo) newRepoFile(entry *git.TreeEntry, dir string, expand bool) (*File
 	return file, nil
 }
 
-// GetFile finds a file or directory
+// GetFile finds a file or directory at the current HEAD
 func (r *Repo) GetFile(pathname string) (*File, error) {
-	var entry *git.TreeEntry
-	ref, err := r.repo.Head()
-	if err != nil {
-		return nil, err
-	}
+	return r.GetFileAt("", pathname)
+}
 
-	obj, err := r.repo.Lookup(ref.Target())
+// GetFileAt finds a file or directory at a specific revision. The ref can be a
+// branch name, a tag or a commit sha. An empty ref means the current HEAD.
+func (r *Repo) GetFileAt(ref, pathname string) (*File, error) {
+	var entry *git.TreeEntry
+	commit, err := r.lookupRevision(ref)
 	if err != nil {
 		return nil, err
 	}
 
-	commit, ok := obj.(*git.Commit)
-	if !ok {
-		return nil, fmt.Errorf("Head is not a commit: %v", obj.Type())
-	}
-
 	tree, err := commit.Tree()
 	if err != nil {
 		return nil, err
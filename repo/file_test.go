@@ -0,0 +1,59 @@
+package repo
+
+import "testing"
+
+func TestGetFileAt(t *testing.T) {
+	r, cleanup := newTestRepo(t, map[string]string{"README.md": "v1", "content/post.md": "post"})
+	defer cleanup()
+
+	first := testRefSha(t, r, "refs/heads/master")
+	second := testCommit(t, r, first, map[string]string{"README.md": "v2"})
+	testSetRef(t, r, "refs/heads/feature", second.Sha)
+	testSetRef(t, r, "refs/tags/v1", first)
+
+	tests := []struct {
+		ref      string
+		path     string
+		content  string
+		fileType string
+		err      bool
+	}{
+		{ref: "", path: "README.md", content: "v1"},
+		{ref: "master", path: "README.md", content: "v1"},
+		{ref: "feature", path: "README.md", content: "v2"},
+		{ref: "refs/heads/feature", path: "README.md", content: "v2"},
+		{ref: "v1", path: "README.md", content: "v1"},
+		{ref: second.Sha, path: "README.md", content: "v2"},
+		{ref: "feature", path: "content", fileType: "dir"},
+		{ref: "feature", path: "content/post.md", content: "post"},
+		{ref: "nope", path: "README.md", err: true},
+		{ref: "master", path: "missing.md", err: true},
+	}
+
+	for _, test := range tests {
+		file, err := r.GetFileAt(test.ref, test.path)
+		if test.err {
+			if _, ok := err.(*NotFoundError); !ok {
+				t.Errorf("GetFileAt(%q, %q): expected a NotFoundError, got %v", test.ref, test.path, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("GetFileAt(%q, %q): unexpected error %v", test.ref, test.path, err)
+			continue
+		}
+		if file.Path != test.path {
+			t.Errorf("GetFileAt(%q, %q): expected path %v, got %v", test.ref, test.path, test.path, file.Path)
+		}
+
+		if test.fileType == "dir" {
+			if file.Type != "dir" || len(file.Files) != 1 || file.Files[0].Path != "content/post.md" {
+				t.Errorf("GetFileAt(%q, %q): expected a dir listing with content/post.md, got %+v", test.ref, test.path, file)
+			}
+			continue
+		}
+		if content := testFileContent(t, r, test.ref, test.path); content != test.content {
+			t.Errorf("GetFileAt(%q, %q): expected %q, got %q", test.ref, test.path, test.content, content)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package repo
+
+import (
+	"fmt"
+
+	"gopkg.in/libgit2/git2go.v22"
+)
+
+// MergeConflict is a path that could not be merged cleanly, with the blob
+// shas of each side (empty if the file doesn't exist on that side)
+type MergeConflict struct {
+	Path     string `json:"path"`
+	Ancestor string `json:"ancestor,omitempty"`
+	Ours     string `json:"ours,omitempty"`
+	Theirs   string `json:"theirs,omitempty"`
+}
+
+// MergeConflictError indicates that a merge has conflicting changes
+type MergeConflictError struct {
+	Conflicts []*MergeConflict
+}
+
+func (e *MergeConflictError) Error() string {
+	return fmt.Sprintf("Merge conflict in %v files", len(e.Conflicts))
+}
+
+// Merge merges head (a branch, tag or sha) into the base branch with a merge
+// commit and updates the branch. Returns nil if there is nothing to merge and
+// a MergeConflictError if the changes can't be merged cleanly or a
+// ConflictError if the base branch moves while merging.
+func (r *Repo) Merge(base, head, msg string) (*Commit, error) {
+	refName := "refs/heads/" + base
+	ref, err := r.repo.LookupReference(refName)
+	if err != nil {
+		return nil, &NotFoundError{id: refName, object: "Ref"}
+	}
+
+	baseCommit, err := r.repo.LookupCommit(ref.Target())
+	if err != nil {
+		return nil, &NotFoundError{id: ref.Target().String(), object: "Commit"}
+	}
+
+	headCommit, err := r.lookupRevision(head)
+	if err != nil {
+		return nil, err
+	}
+
+	if baseCommit.Id().Equal(headCommit.Id()) {
+		return nil, nil
+	}
+	merged, err := r.repo.DescendantOf(baseCommit.Id(), headCommit.Id())
+	if err != nil {
+		return nil, err
+	}
+	if merged {
+		return nil, nil
+	}
+
+	index, err := r.repo.MergeCommits(baseCommit, headCommit, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer index.Free()
+
+	if index.HasConflicts() {
+		conflicts, err := indexConflicts(index)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &MergeConflictError{Conflicts: conflicts}
+	}
+
+	treeID, err := index.WriteTreeTo(r.repo)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg == "" {
+		msg = fmt.Sprintf("Merge %v into %v", head, base)
+	}
+
+	commit, err := r.CreateCommit(treeID.String(), msg, []string{baseCommit.Id().String(), headCommit.Id().String()})
+	if err != nil {
+		return nil, err
+	}
+
+	// Only move the branch if it still points to the commit that was merged
+	if _, err := r.UpdateRefFrom(refName, baseCommit.Id().String(), commit.Sha, false); err != nil {
+		return nil, err
+	}
+
+	return commit, nil
+}
+
+func indexConflicts(index *git.Index) ([]*MergeConflict, error) {
+	iter, err := index.ConflictIterator()
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Free()
+
+	conflicts := []*MergeConflict{}
+	for {
+		entry, err := iter.Next()
+		if git.IsErrorCode(err, git.ErrIterOver) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		conflict := &MergeConflict{}
+		if entry.Ancestor != nil {
+			conflict.Path = entry.Ancestor.Path
+			conflict.Ancestor = entry.Ancestor.Id.String()
+		}
+		if entry.Our != nil {
+			conflict.Path = entry.Our.Path
+			conflict.Ours = entry.Our.Id.String()
+		}
+		if entry.Their != nil {
+			conflict.Path = entry.Their.Path
+			conflict.Theirs = entry.Their.Id.String()
+		}
+		conflicts = append(conflicts, conflict)
+	}
+
+	return conflicts, nil
+}
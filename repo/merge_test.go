@@ -0,0 +1,126 @@
+package repo
+
+import "testing"
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name      string
+		master    map[string]string
+		feature   map[string]string
+		merged    bool
+		conflicts []string
+		files     map[string]string
+	}{
+		{
+			name:    "clean merge",
+			master:  map[string]string{"master.md": "master"},
+			feature: map[string]string{"feature.md": "feature"},
+			merged:  true,
+			files:   map[string]string{"README.md": "one\ntwo\nthree\nfour\nfive\n", "master.md": "master", "feature.md": "feature"},
+		},
+		{
+			name:    "merge changes in one file",
+			master:  map[string]string{"README.md": "1\ntwo\nthree\nfour\nfive\n"},
+			feature: map[string]string{"README.md": "one\ntwo\nthree\nfour\n5\n"},
+			merged:  true,
+			files:   map[string]string{"README.md": "1\ntwo\nthree\nfour\n5\n"},
+		},
+		{
+			name:      "conflict",
+			master:    map[string]string{"README.md": "master\n", "master.md": "master"},
+			feature:   map[string]string{"README.md": "feature\n"},
+			conflicts: []string{"README.md"},
+		},
+		{
+			name:      "delete and modify",
+			master:    map[string]string{"README.md": ""},
+			feature:   map[string]string{"README.md": "feature\n"},
+			conflicts: []string{"README.md"},
+		},
+		{
+			name:    "master behind",
+			feature: map[string]string{"feature.md": "feature"},
+			merged:  true,
+			files:   map[string]string{"feature.md": "feature"},
+		},
+		{
+			name:   "already merged",
+			master: map[string]string{"master.md": "master"},
+		},
+	}
+
+	for _, test := range tests {
+		r, cleanup := newTestRepo(t, map[string]string{"README.md": "one\ntwo\nthree\nfour\nfive\n"})
+		base := testRefSha(t, r, "refs/heads/master")
+		master, feature := base, base
+		if test.master != nil {
+			master = testCommit(t, r, base, test.master).Sha
+		}
+		if test.feature != nil {
+			feature = testCommit(t, r, base, test.feature).Sha
+		}
+		testSetRef(t, r, "refs/heads/master", master)
+		testSetRef(t, r, "refs/heads/feature", feature)
+
+		commit, err := r.Merge("master", "feature", "")
+		switch {
+		case test.conflicts != nil:
+			conflictErr, ok := err.(*MergeConflictError)
+			if !ok {
+				t.Errorf("%v: expected a MergeConflictError, got %v", test.name, err)
+				break
+			}
+			paths := []string{}
+			for _, conflict := range conflictErr.Conflicts {
+				paths = append(paths, conflict.Path)
+				if conflict.Theirs == "" {
+					t.Errorf("%v: expected the sha of the conflicting file in feature", test.name)
+				}
+			}
+			if len(paths) != len(test.conflicts) || paths[0] != test.conflicts[0] {
+				t.Errorf("%v: expected conflicts in %v, got %v", test.name, test.conflicts, paths)
+			}
+			if sha := testRefSha(t, r, "refs/heads/master"); sha != master {
+				t.Errorf("%v: expected master to stay at %v, got %v", test.name, master, sha)
+			}
+		case err != nil:
+			t.Errorf("%v: unexpected error %v", test.name, err)
+		case !test.merged:
+			if commit != nil {
+				t.Errorf("%v: expected nothing to merge, got %v", test.name, commit.Sha)
+			}
+		default:
+			if commit == nil {
+				t.Errorf("%v: expected a merge commit", test.name)
+				break
+			}
+			if len(commit.Parents) != 2 || commit.Parents[0].Sha != master || commit.Parents[1].Sha != feature {
+				t.Errorf("%v: expected parents %v and %v, got %+v", test.name, master, feature, commit.Parents)
+			}
+			if commit.Message != "Merge feature into master" {
+				t.Errorf("%v: unexpected message %q", test.name, commit.Message)
+			}
+			if sha := testRefSha(t, r, "refs/heads/master"); sha != commit.Sha {
+				t.Errorf("%v: expected master to point to the merge %v, got %v", test.name, commit.Sha, sha)
+			}
+			for pathname, content := range test.files {
+				if actual := testFileContent(t, r, "master", pathname); actual != content {
+					t.Errorf("%v: expected %v to be %q, got %q", test.name, pathname, content, actual)
+				}
+			}
+		}
+		cleanup()
+	}
+}
+
+func TestMergeErrors(t *testing.T) {
+	r, cleanup := newTestRepo(t, map[string]string{"README.md": "readme"})
+	defer cleanup()
+
+	if _, err := r.Merge("missing", "master", ""); !sameErrorType(err, &NotFoundError{}) {
+		t.Errorf("Expected a NotFoundError for a missing base, got %v", err)
+	}
+	if _, err := r.Merge("master", "missing", ""); !sameErrorType(err, &NotFoundError{}) {
+		t.Errorf("Expected a NotFoundError for a missing head, got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package repo
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"gopkg.in/libgit2/git2go.v22"
+)
+
+// BranchProtection restricts updates to the refs matching Pattern
+// (ie. master, refs/heads/release-*). If Users or Roles are set, only those
+// users (by email) or users with one of those roles (granted directly or
+// through one of their groups) may change the ref.
+// RequireMergeFrom lists branch patterns (ie. cms/*) that updates must be a
+// merge of.
+type BranchProtection struct {
+	Pattern          string   `yaml:"pattern"`
+	ForbidForce      bool     `yaml:"forbid_force"`
+	ForbidDelete     bool     `yaml:"forbid_delete"`
+	Users            []string `yaml:"users"`
+	Roles            []string `yaml:"roles"`
+	RequireMergeFrom []string `yaml:"require_merge_from"`
+}
+
+// Protect sets the branch protection rules for this repo
+func (r *Repo) Protect(protections []*BranchProtection) {
+	r.protections = protections
+}
+
+// RestrictBranches limits the refs the repo user can create, update or delete
+// to the ones matching one of the branch patterns. No patterns means no
+// restriction.
+func (r *Repo) RestrictBranches(patterns []string) {
+	r.branches = patterns
+}
+
+// Matches checks if the protection applies to a ref name
+func (p *BranchProtection) Matches(name string) bool {
+	ok, err := path.Match(refPattern(p.Pattern), name)
+	return err == nil && ok
+}
+
+func (p *BranchProtection) allowsUser(user User) bool {
+	if len(p.Users) == 0 && len(p.Roles) == 0 {
+		return true
+	}
+	for _, email := range p.Users {
+		if email == user.Email() {
+			return true
+		}
+	}
+	for _, role := range p.Roles {
+		if user.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// refPattern expands a branch pattern to a full ref pattern
+func refPattern(pattern string) string {
+	if strings.HasPrefix(pattern, "refs/") {
+		return pattern
+	}
+	return "refs/heads/" + pattern
+}
+
+// checkBranchRestriction verifies that a ref is in the branches the repo user
+// is restricted to
+func (r *Repo) checkBranchRestriction(name string) error {
+	if len(r.branches) == 0 {
+		return nil
+	}
+	for _, pattern := range r.branches {
+		if ok, err := path.Match(refPattern(pattern), name); err == nil && ok {
+			return nil
+		}
+	}
+	return &ForbiddenError{msg: fmt.Sprintf("You are not allowed to change %v", name)}
+}
+
+func (r *Repo) protectionsFor(name string) []*BranchProtection {
+	matches := []*BranchProtection{}
+	for _, p := range r.protections {
+		if p.Matches(name) {
+			matches = append(matches, p)
+		}
+	}
+	return matches
+}
+
+// checkCreateProtection verifies that the repo user may create a ref
+func (r *Repo) checkCreateProtection(name string) error {
+	if err := r.checkBranchRestriction(name); err != nil {
+		return err
+	}
+	for _, p := range r.protectionsFor(name) {
+		if !p.allowsUser(r.user) {
+			return &ForbiddenError{msg: fmt.Sprintf("%v is protected, you are not allowed to create it", name)}
+		}
+	}
+	return nil
+}
+
+// checkDeleteProtection verifies that the repo user may delete a ref
+func (r *Repo) checkDeleteProtection(name string) error {
+	if err := r.checkBranchRestriction(name); err != nil {
+		return err
+	}
+	for _, p := range r.protectionsFor(name) {
+		if p.ForbidDelete {
+			return &ForbiddenError{msg: fmt.Sprintf("%v is protected and can't be deleted", name)}
+		}
+		if !p.allowsUser(r.user) {
+			return &ForbiddenError{msg: fmt.Sprintf("%v is protected, you are not allowed to delete it", name)}
+		}
+	}
+	return nil
+}
+
+// checkUpdateProtection verifies that the repo user may move a ref from the
+// old to the new commit
+func (r *Repo) checkUpdateProtection(name string, oldCommit, newCommit *Commit, fastForward bool) error {
+	if err := r.checkBranchRestriction(name); err != nil {
+		return err
+	}
+	for _, p := range r.protectionsFor(name) {
+		if !p.allowsUser(r.user) {
+			return &ForbiddenError{msg: fmt.Sprintf("%v is protected, you are not allowed to update it", name)}
+		}
+		if p.ForbidForce && !fastForward {
+			return &ForbiddenError{msg: fmt.Sprintf("%v is protected and can't be force updated", name)}
+		}
+		if len(p.RequireMergeFrom) > 0 {
+			ok, err := r.isMergeFrom(oldCommit, newCommit, p.RequireMergeFrom)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return &ForbiddenError{msg: fmt.Sprintf("%v is protected and can only be updated by merging %v", name, strings.Join(p.RequireMergeFrom, ", "))}
+			}
+		}
+	}
+	return nil
+}
+
+// isMergeFrom checks if newCommit is a merge on top of oldCommit of the
+// current tip of a branch matching one of the patterns
+func (r *Repo) isMergeFrom(oldCommit, newCommit *Commit, patterns []string) (bool, error) {
+	if len(newCommit.Parents) < 2 || newCommit.Parents[0].Sha != oldCommit.Sha {
+		return false, nil
+	}
+
+	iter, err := r.repo.NewReferenceIterator()
+	if err != nil {
+		return false, err
+	}
+	defer iter.Free()
+
+	for {
+		ref, err := iter.Next()
+		if git.IsErrorCode(err, git.ErrIterOver) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		matches := false
+		for _, pattern := range patterns {
+			if ok, err := path.Match(refPattern(pattern), ref.Name()); err == nil && ok {
+				matches = true
+			}
+		}
+		if !matches || ref.Target() == nil {
+			continue
+		}
+
+		for _, parent := range newCommit.Parents[1:] {
+			if parent.Sha == ref.Target().String() {
+				return true, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package repo
+
+import "testing"
+
+func TestBranchProtectionMatches(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		name     string
+		expected bool
+	}{
+		{pattern: "master", name: "refs/heads/master", expected: true},
+		{pattern: "master", name: "refs/heads/master-2", expected: false},
+		{pattern: "release-*", name: "refs/heads/release-1", expected: true},
+		{pattern: "release-*", name: "refs/heads/cms/release-1", expected: false},
+		{pattern: "refs/tags/*", name: "refs/tags/v1", expected: true},
+		{pattern: "refs/tags/*", name: "refs/heads/v1", expected: false},
+	}
+
+	for _, test := range tests {
+		p := &BranchProtection{Pattern: test.pattern}
+		if matches := p.Matches(test.name); matches != test.expected {
+			t.Errorf("Matches(%q, %q): expected %v, got %v", test.pattern, test.name, test.expected, matches)
+		}
+	}
+}
+
+func TestBranchProtectionAllowsUser(t *testing.T) {
+	user := &testUser{email: "editor@example.com", roles: []string{"editor"}}
+
+	tests := []struct {
+		name       string
+		protection *BranchProtection
+		expected   bool
+	}{
+		{name: "no restriction", protection: &BranchProtection{}, expected: true},
+		{name: "listed user", protection: &BranchProtection{Users: []string{"editor@example.com"}}, expected: true},
+		{name: "other user", protection: &BranchProtection{Users: []string{"admin@example.com"}}, expected: false},
+		{name: "listed role", protection: &BranchProtection{Roles: []string{"admin", "editor"}}, expected: true},
+		{name: "other role", protection: &BranchProtection{Roles: []string{"admin"}}, expected: false},
+		{name: "other user with role", protection: &BranchProtection{Users: []string{"admin@example.com"}, Roles: []string{"editor"}}, expected: true},
+	}
+
+	for _, test := range tests {
+		if allowed := test.protection.allowsUser(user); allowed != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.name, test.expected, allowed)
+		}
+	}
+}
+
+func TestProtectedUpdates(t *testing.T) {
+	r, cleanup := newTestRepo(t, map[string]string{"README.md": "readme"})
+	defer cleanup()
+
+	base := testRefSha(t, r, "refs/heads/master")
+	ahead := testCommit(t, r, base, map[string]string{"README.md": "ahead"})
+	diverged := testCommit(t, r, base, map[string]string{"README.md": "diverged"})
+	cms := testCommit(t, r, base, map[string]string{"content/post.md": "post"})
+	other := testCommit(t, r, base, map[string]string{"content/other.md": "other"})
+	testSetRef(t, r, "refs/heads/cms/post", cms.Sha)
+	testSetRef(t, r, "refs/heads/other", other.Sha)
+
+	mergeCMS, err := r.CreateCommit(cms.Tree.Sha, "Merge cms/post", []string{base, cms.Sha})
+	if err != nil {
+		t.Fatalf("Error creating merge: %v", err)
+	}
+	mergeOther, err := r.CreateCommit(other.Tree.Sha, "Merge other", []string{base, other.Sha})
+	if err != nil {
+		t.Fatalf("Error creating merge: %v", err)
+	}
+
+	editor := &testUser{name: "Editor", email: "editor@example.com", roles: []string{"editor"}}
+	contributor := &testUser{name: "Contributor", email: "contributor@example.com", roles: []string{"contributor"}}
+
+	tests := []struct {
+		name       string
+		protection *BranchProtection
+		user       *testUser
+		sha        string
+		force      bool
+		err        error
+	}{
+		{name: "unprotected", protection: &BranchProtection{Pattern: "release-*"}, user: contributor, sha: ahead.Sha},
+		{name: "allowed role", protection: &BranchProtection{Pattern: "master", Roles: []string{"editor"}}, user: editor, sha: ahead.Sha},
+		{name: "other role", protection: &BranchProtection{Pattern: "master", Roles: []string{"editor"}}, user: contributor, sha: ahead.Sha, err: &ForbiddenError{}},
+		{name: "allowed user", protection: &BranchProtection{Pattern: "master", Users: []string{"contributor@example.com"}}, user: contributor, sha: ahead.Sha},
+		{name: "force allowed", protection: &BranchProtection{Pattern: "master"}, user: editor, sha: diverged.Sha, force: true},
+		{name: "force forbidden", protection: &BranchProtection{Pattern: "master", ForbidForce: true}, user: editor, sha: diverged.Sha, force: true, err: &ForbiddenError{}},
+		{name: "merge from cms", protection: &BranchProtection{Pattern: "master", RequireMergeFrom: []string{"cms/*"}}, user: editor, sha: mergeCMS.Sha},
+		{name: "merge from other", protection: &BranchProtection{Pattern: "master", RequireMergeFrom: []string{"cms/*"}}, user: editor, sha: mergeOther.Sha, err: &ForbiddenError{}},
+		{name: "not a merge", protection: &BranchProtection{Pattern: "master", RequireMergeFrom: []string{"cms/*"}}, user: editor, sha: ahead.Sha, err: &ForbiddenError{}},
+	}
+
+	for _, test := range tests {
+		testSetRef(t, r, "refs/heads/master", base)
+		r.user = test.user
+		r.Protect([]*BranchProtection{test.protection})
+
+		_, err := r.UpdateRef("refs/heads/master", test.sha, test.force)
+		if test.err != nil {
+			if !sameErrorType(err, test.err) {
+				t.Errorf("%v: expected a %T, got %v", test.name, test.err, err)
+			}
+			if sha := testRefSha(t, r, "refs/heads/master"); sha != base {
+				t.Errorf("%v: expected master to stay at %v, got %v", test.name, base, sha)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", test.name, err)
+			continue
+		}
+		if sha := testRefSha(t, r, "refs/heads/master"); sha != test.sha {
+			t.Errorf("%v: expected master to point to %v, got %v", test.name, test.sha, sha)
+		}
+	}
+}
+
+func TestProtectedCreateAndDelete(t *testing.T) {
+	r, cleanup := newTestRepo(t, map[string]string{"README.md": "readme"})
+	defer cleanup()
+
+	master := testRefSha(t, r, "refs/heads/master")
+	r.user = &testUser{name: "Contributor", email: "contributor@example.com", roles: []string{"contributor"}}
+	r.Protect([]*BranchProtection{
+		{Pattern: "release-*", Roles: []string{"admin"}},
+		{Pattern: "stable", ForbidDelete: true},
+	})
+
+	if _, err := r.CreateRef("refs/heads/release-1", master); !sameErrorType(err, &ForbiddenError{}) {
+		t.Errorf("Expected creating a protected branch to be forbidden, got %v", err)
+	}
+	if _, err := r.CreateRef("refs/heads/stable", master); err != nil {
+		t.Fatalf("Unexpected error creating stable: %v", err)
+	}
+	if err := r.DeleteRef("refs/heads/stable"); !sameErrorType(err, &ForbiddenError{}) {
+		t.Errorf("Expected deleting a protected branch to be forbidden, got %v", err)
+	}
+
+	testSetRef(t, r, "refs/heads/release-1", master)
+	if err := r.DeleteRef("refs/heads/release-1"); !sameErrorType(err, &ForbiddenError{}) {
+		t.Errorf("Expected deleting a branch protected by role to be forbidden, got %v", err)
+	}
+}
+
+func TestRestrictBranches(t *testing.T) {
+	r, cleanup := newTestRepo(t, map[string]string{"README.md": "readme"})
+	defer cleanup()
+
+	master := testRefSha(t, r, "refs/heads/master")
+	ahead := testCommit(t, r, master, map[string]string{"README.md": "ahead"})
+	testSetRef(t, r, "refs/heads/cms/post", master)
+	r.RestrictBranches([]string{"cms/*"})
+
+	if _, err := r.UpdateRef("refs/heads/cms/post", ahead.Sha, false); err != nil {
+		t.Errorf("Unexpected error updating an allowed branch: %v", err)
+	}
+	if _, err := r.UpdateRef("refs/heads/master", ahead.Sha, false); !sameErrorType(err, &ForbiddenError{}) {
+		t.Errorf("Expected updating master to be forbidden, got %v", err)
+	}
+	if _, err := r.CreateRef("refs/heads/feature", master); !sameErrorType(err, &ForbiddenError{}) {
+		t.Errorf("Expected creating a branch outside the restriction to be forbidden, got %v", err)
+	}
+	if _, err := r.CreateRef("refs/heads/cms/new", master); err != nil {
+		t.Errorf("Unexpected error creating an allowed branch: %v", err)
+	}
+	if err := r.DeleteRef("refs/heads/cms/new"); err != nil {
+		t.Errorf("Unexpected error deleting an allowed branch: %v", err)
+	}
+}
@@ -9,6 +9,10 @@ import (
 	"gopkg.in/libgit2/git2go.v22"
 )
 
+// errModified is GIT_EMODIFIED, returned by libgit2 when a reference changed
+// after it was looked up
+const errModified = git.ErrorCode(-15)
+
 // Reference represents a git reference
 type Reference struct {
 	Name   string     `json:"ref"`
@@ -21,6 +25,16 @@ type RefObject struct {
 	Sha  string `json:"sha"`
 }
 
+func newReference(name string, target *git.Oid) *Reference {
+	return &Reference{
+		Name: name,
+		Object: &RefObject{
+			Type: "commit", // This might not always be true?
+			Sha:  target.String(),
+		},
+	}
+}
+
 // GetRef looks up a reference from a name (ie. refs/heads/master)
 func (r *Repo) GetRef(name string) (*Reference, error) {
 	ref, err := r.repo.LookupReference(name)
@@ -28,68 +42,176 @@ func (r *Repo) GetRef(name string) (*Reference, error) {
 		return nil, &NotFoundError{id: name, object: "Ref"}
 	}
 
-	return &Reference{
-		Name: name,
-		Object: &RefObject{
-			Type: "commit", // This might not always be true?
-			Sha:  ref.Target().String(),
-		},
-	}, nil
+	return newReference(name, ref.Target()), nil
+}
 
+// ListRefs returns all references with a name starting with prefix
+// (ie. refs/heads/)
+func (r *Repo) ListRefs(prefix string) ([]*Reference, error) {
+	iter, err := r.repo.NewReferenceIterator()
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Free()
+
+	refs := []*Reference{}
+	for {
+		ref, err := iter.Next()
+		if git.IsErrorCode(err, git.ErrIterOver) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := ref.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		ref, err = ref.Resolve()
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, newReference(name, ref.Target()))
+	}
+
+	return refs, nil
+}
+
+// CreateRef creates a new reference pointing to a commit
+// Will check if the repo user has sufficient permissions to
+// introduce the changes between HEAD and the commit
+func (r *Repo) CreateRef(name, sha string) (*Reference, error) {
+	if _, err := r.repo.LookupReference(name); err == nil {
+		return nil, &ConflictError{msg: fmt.Sprintf("Ref %v already exists", name)}
+	}
+
+	oid, err := parseOid(sha)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.checkCreateProtection(name); err != nil {
+		return nil, err
+	}
+
+	headCommit, err := r.headCommit()
+	if err != nil {
+		return nil, err
+	}
+
+	newCommit, err := r.GetCommit(sha)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := r.checkPermissions(headCommit, newCommit); err != nil {
+		return nil, err
+	}
+
+	ref, err := r.repo.CreateReference(name, oid, false, r.signature(), "")
+	if err != nil {
+		return nil, err
+	}
+
+	return newReference(name, ref.Target()), nil
+}
+
+// DeleteRef removes a reference
+// Will check if the repo user is allowed to delete the reference by the
+// branch protection rules. Deleting a ref doesn't change any content, so the
+// per file permissions don't apply.
+func (r *Repo) DeleteRef(name string) error {
+	ref, err := r.repo.LookupReference(name)
+	if err != nil {
+		return &NotFoundError{id: name, object: "Ref"}
+	}
+
+	if r.isHead(name) {
+		return &ConflictError{msg: fmt.Sprintf("Ref %v is the current HEAD", name)}
+	}
+
+	if err := r.checkDeleteProtection(name); err != nil {
+		return err
+	}
+
+	return ref.Delete()
 }
 
 // UpdateRef updates a reference to point to a new object
 // Will check if the repo user has sufficient permissions to
-// perform this update
-func (r *Repo) UpdateRef(name, newSha string) (*Reference, error) {
+// perform this update. Unless force is set, the new commit must
+// be a descendant of the current target.
+func (r *Repo) UpdateRef(name, newSha string, force bool) (*Reference, error) {
+	return r.UpdateRefFrom(name, "", newSha, force)
+}
+
+// UpdateRefFrom is like UpdateRef but only moves the reference if it still
+// points to oldSha. The reference is swapped atomically, if it changes in the
+// meantime the update fails with a ConflictError. An empty oldSha accepts any
+// current target.
+func (r *Repo) UpdateRefFrom(name, oldSha, newSha string, force bool) (*Reference, error) {
 	ref, err := r.repo.LookupReference(name)
 	if err != nil {
 		return nil, &NotFoundError{id: name, object: "Ref"}
 	}
 
-	oid, err := git.NewOid(newSha)
-	if err != nil {
-		return nil, err
+	if oldSha != "" && ref.Target().String() != oldSha {
+		return nil, refMoved(name, ref.Target().String())
 	}
 
-	oldCommit, err := r.GetCommit(ref.Target().String())
+	oid, err := parseOid(newSha)
 	if err != nil {
 		return nil, err
 	}
 
-	newCommit, err := r.GetCommit(newSha)
+	oldCommit, err := r.GetCommit(ref.Target().String())
 	if err != nil {
 		return nil, err
 	}
 
-	changes, err := oldCommit.ChangedFiles(newCommit)
+	newCommit, err := r.GetCommit(newSha)
 	if err != nil {
 		return nil, err
 	}
 
-	failMsg := []string{}
-	for _, change := range changes {
-		if !r.user.HasPermission(change.Action, change.Path) {
-			failMsg = append(failMsg, fmt.Sprintf("you do not have permission to %v: %v", change.Action, change.Path))
+	ff := oid.Equal(oldCommit.id)
+	if !ff {
+		ff, err = r.repo.DescendantOf(oid, oldCommit.id)
+		if err != nil {
+			return nil, err
 		}
 	}
+	if !force && !ff {
+		return nil, &NonFastForwardError{Ref: name, Current: oldCommit.Sha, Sha: newSha}
+	}
 
-	if len(failMsg) > 0 {
-		return nil, &ForbiddenError{msg: strings.Join(failMsg, ",")}
+	if err := r.checkUpdateProtection(name, oldCommit, newCommit, ff); err != nil {
+		return nil, err
 	}
 
-	sig := &git.Signature{
-		Name:  r.user.Name(),
-		Email: r.user.Email(),
-		When:  time.Now(),
+	changes, err := r.checkPermissions(oldCommit, newCommit)
+	if err != nil {
+		return nil, err
 	}
 
-	ref, err = ref.SetTarget(oid, sig, "")
+	// SetTarget only succeeds if the ref still points to the target it was
+	// looked up with
+	ref, err = ref.SetTarget(oid, r.signature(), "")
+	if git.IsErrorCode(err, errModified) {
+		current, _ := r.GetRef(name)
+		if current == nil {
+			return nil, refMoved(name, "")
+		}
+		return nil, refMoved(name, current.Object.Sha)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	if !r.repo.IsBare() {
+	// Only the checked out branch should touch the working directory
+	if !r.repo.IsBare() && r.isHead(name) {
 		paths := make([]string, len(changes))
 		del := false
 		for i, change := range changes {
@@ -113,11 +235,115 @@ func (r *Repo) UpdateRef(name, newSha string) (*Reference, error) {
 		}
 	}
 
-	return &Reference{
-		Name: name,
-		Object: &RefObject{
-			Type: "commit", // This might not always be true?
-			Sha:  ref.Target().String(),
-		},
-	}, nil
+	return newReference(name, ref.Target()), nil
+}
+
+// refMoved is the error for a reference that doesn't point to the expected sha
+func refMoved(name, sha string) error {
+	return &ConflictError{msg: fmt.Sprintf("Ref %v has moved, current sha is %v", name, sha)}
+}
+
+// checkPermissions verifies that the repo user is allowed to make every file
+// change between two commits and returns the changes
+func (r *Repo) checkPermissions(oldCommit, newCommit *Commit) ([]*FileChange, error) {
+	changes, err := oldCommit.ChangedFiles(newCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	failMsg := []string{}
+	for _, change := range changes {
+		if !r.user.HasPermission(change.Action, change.Path) {
+			failMsg = append(failMsg, fmt.Sprintf("you do not have permission to %v: %v", change.Action, change.Path))
+		}
+	}
+
+	if len(failMsg) > 0 {
+		return nil, &ForbiddenError{msg: strings.Join(failMsg, ",")}
+	}
+
+	return changes, nil
+}
+
+// headCommit returns the commit the current HEAD points to
+func (r *Repo) headCommit() (*Commit, error) {
+	head, err := r.repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	return r.GetCommit(head.Target().String())
+}
+
+// isHead checks if name is the reference HEAD currently points to
+func (r *Repo) isHead(name string) bool {
+	head, err := r.repo.Head()
+	if err != nil {
+		return false
+	}
+	return head.Name() == name
+}
+
+func (r *Repo) signature() *git.Signature {
+	return &git.Signature{
+		Name:  r.user.Name(),
+		Email: r.user.Email(),
+		When:  time.Now(),
+	}
+}
+
+// lookupRevision resolves a branch name, tag, full reference name or commit
+// sha to a commit. An empty revision resolves to the current HEAD.
+func (r *Repo) lookupRevision(rev string) (*git.Commit, error) {
+	var target *git.Oid
+	if rev == "" {
+		head, err := r.repo.Head()
+		if err != nil {
+			return nil, err
+		}
+		target = head.Target()
+	} else {
+		for _, name := range []string{rev, "refs/heads/" + rev, "refs/tags/" + rev} {
+			if !strings.HasPrefix(name, "refs/") {
+				continue
+			}
+			ref, err := r.repo.LookupReference(name)
+			if err != nil {
+				continue
+			}
+			ref, err = ref.Resolve()
+			if err != nil {
+				return nil, err
+			}
+			target = ref.Target()
+			break
+		}
+	}
+
+	if target == nil {
+		oid, err := git.NewOid(rev)
+		if err != nil {
+			return nil, &NotFoundError{id: rev, object: "Revision"}
+		}
+		target = oid
+	}
+
+	obj, err := r.repo.Lookup(target)
+	if err != nil {
+		return nil, &NotFoundError{id: rev, object: "Revision"}
+	}
+
+	// Annotated tags point to a tag object rather than the commit
+	if tag, ok := obj.(*git.Tag); ok {
+		obj, err = r.repo.Lookup(tag.TargetId())
+		if err != nil {
+			return nil, &NotFoundError{id: rev, object: "Revision"}
+		}
+	}
+
+	commit, ok := obj.(*git.Commit)
+	if !ok {
+		return nil, fmt.Errorf("%v is not a commit: %v", rev, obj.Type())
+	}
+
+	return commit, nil
 }
@@ -0,0 +1,238 @@
+package repo
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"gopkg.in/libgit2/git2go.v22"
+)
+
+func TestListRefs(t *testing.T) {
+	r, cleanup := newTestRepo(t, map[string]string{"README.md": "readme"})
+	defer cleanup()
+
+	master := testRefSha(t, r, "refs/heads/master")
+	testSetRef(t, r, "refs/heads/feature", master)
+	testSetRef(t, r, "refs/tags/v1", master)
+
+	tests := []struct {
+		prefix string
+		names  []string
+	}{
+		{prefix: "refs/", names: []string{"refs/heads/feature", "refs/heads/master", "refs/tags/v1"}},
+		{prefix: "refs/heads/", names: []string{"refs/heads/feature", "refs/heads/master"}},
+		{prefix: "refs/tags/", names: []string{"refs/tags/v1"}},
+		{prefix: "refs/remotes/", names: []string{}},
+	}
+
+	for _, test := range tests {
+		refs, err := r.ListRefs(test.prefix)
+		if err != nil {
+			t.Errorf("ListRefs(%q): unexpected error %v", test.prefix, err)
+			continue
+		}
+		names := []string{}
+		for _, ref := range refs {
+			names = append(names, ref.Name)
+			if ref.Object.Sha != master {
+				t.Errorf("ListRefs(%q): expected %v to point to %v, got %v", test.prefix, ref.Name, master, ref.Object.Sha)
+			}
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, test.names) {
+			t.Errorf("ListRefs(%q): expected %v, got %v", test.prefix, test.names, names)
+		}
+	}
+}
+
+func TestCreateRef(t *testing.T) {
+	r, cleanup := newTestRepo(t, map[string]string{"README.md": "readme"})
+	defer cleanup()
+
+	master := testRefSha(t, r, "refs/heads/master")
+	change := testCommit(t, r, master, map[string]string{"secret.md": "secret"})
+	r.user = &testUser{name: "Test User", email: "test@example.com", denied: []string{"create:secret.md"}}
+
+	tests := []struct {
+		name string
+		sha  string
+		err  error
+	}{
+		{name: "refs/heads/feature", sha: master},
+		{name: "refs/heads/master", sha: master, err: &ConflictError{}},
+		{name: "refs/heads/bad", sha: "not-a-sha", err: &InvalidShaError{}},
+		{name: "refs/heads/missing", sha: "0123456789012345678901234567890123456789", err: &NotFoundError{}},
+		{name: "refs/heads/secret", sha: change.Sha, err: &ForbiddenError{}},
+	}
+
+	for _, test := range tests {
+		ref, err := r.CreateRef(test.name, test.sha)
+		if test.err != nil {
+			if !sameErrorType(err, test.err) {
+				t.Errorf("CreateRef(%q, %q): expected a %T, got %v", test.name, test.sha, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("CreateRef(%q, %q): unexpected error %v", test.name, test.sha, err)
+			continue
+		}
+		if ref.Name != test.name || ref.Object.Sha != test.sha {
+			t.Errorf("CreateRef(%q, %q): got %v pointing to %v", test.name, test.sha, ref.Name, ref.Object.Sha)
+		}
+	}
+}
+
+func TestDeleteRef(t *testing.T) {
+	r, cleanup := newTestRepo(t, map[string]string{"README.md": "readme"})
+	defer cleanup()
+
+	master := testRefSha(t, r, "refs/heads/master")
+	testSetRef(t, r, "refs/heads/feature", master)
+	testSetRef(t, r, "refs/heads/secret", testCommit(t, r, master, map[string]string{"secret.md": "secret"}).Sha)
+	testSetRef(t, r, "refs/heads/release", master)
+	r.user = &testUser{name: "Test User", email: "test@example.com", denied: []string{"delete:secret.md", "create:secret.md"}}
+	r.Protect([]*BranchProtection{{Pattern: "release", ForbidDelete: true}})
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "refs/heads/feature"},
+		{name: "refs/heads/feature", err: &NotFoundError{}},
+		{name: "refs/heads/master", err: &ConflictError{}},
+		{name: "refs/heads/secret"},
+		{name: "refs/heads/release", err: &ForbiddenError{}},
+	}
+
+	for _, test := range tests {
+		err := r.DeleteRef(test.name)
+		if test.err != nil {
+			if !sameErrorType(err, test.err) {
+				t.Errorf("DeleteRef(%q): expected a %T, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("DeleteRef(%q): unexpected error %v", test.name, err)
+			continue
+		}
+		if _, err := r.GetRef(test.name); err == nil {
+			t.Errorf("DeleteRef(%q): expected the ref to be gone", test.name)
+		}
+	}
+}
+
+func TestUpdateRefFastForward(t *testing.T) {
+	r, cleanup := newTestRepo(t, map[string]string{"README.md": "readme"})
+	defer cleanup()
+
+	base := testRefSha(t, r, "refs/heads/master")
+	ahead := testCommit(t, r, base, map[string]string{"README.md": "ahead"})
+	diverged := testCommit(t, r, base, map[string]string{"README.md": "diverged"})
+
+	tests := []struct {
+		start string
+		sha   string
+		force bool
+		err   error
+	}{
+		{start: base, sha: base},
+		{start: base, sha: ahead.Sha},
+		{start: ahead.Sha, sha: base, err: &NonFastForwardError{}},
+		{start: ahead.Sha, sha: diverged.Sha, err: &NonFastForwardError{}},
+		{start: ahead.Sha, sha: base, force: true},
+		{start: ahead.Sha, sha: diverged.Sha, force: true},
+		{start: base, sha: "not-a-sha", err: &InvalidShaError{}},
+		{start: base, sha: "0123456789012345678901234567890123456789", err: &NotFoundError{}},
+	}
+
+	for _, test := range tests {
+		testSetRef(t, r, "refs/heads/feature", test.start)
+		ref, err := r.UpdateRef("refs/heads/feature", test.sha, test.force)
+		if test.err != nil {
+			if !sameErrorType(err, test.err) {
+				t.Errorf("UpdateRef(%v -> %v, %v): expected a %T, got %v", test.start, test.sha, test.force, test.err, err)
+			}
+			if sha := testRefSha(t, r, "refs/heads/feature"); sha != test.start {
+				t.Errorf("UpdateRef(%v -> %v, %v): expected the ref to stay at %v, got %v", test.start, test.sha, test.force, test.start, sha)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("UpdateRef(%v -> %v, %v): unexpected error %v", test.start, test.sha, test.force, err)
+			continue
+		}
+		if ref.Object.Sha != test.sha {
+			t.Errorf("UpdateRef(%v -> %v, %v): expected the ref to point to %v, got %v", test.start, test.sha, test.force, test.sha, ref.Object.Sha)
+		}
+	}
+
+	if _, err := r.UpdateRef("refs/heads/missing", base, false); !sameErrorType(err, &NotFoundError{}) {
+		t.Errorf("UpdateRef of a missing ref: expected a NotFoundError, got %v", err)
+	}
+}
+
+func TestUpdateRefFrom(t *testing.T) {
+	r, cleanup := newTestRepo(t, map[string]string{"README.md": "readme"})
+	defer cleanup()
+
+	base := testRefSha(t, r, "refs/heads/master")
+	next := testCommit(t, r, base, map[string]string{"README.md": "next"}).Sha
+	other := testCommit(t, r, base, map[string]string{"README.md": "other"}).Sha
+
+	tests := []struct {
+		old string
+		sha string
+		err error
+	}{
+		{old: base, sha: next},
+		{old: "", sha: next},
+		{old: other, sha: next, err: &ConflictError{}},
+		{old: next, sha: next, err: &ConflictError{}},
+	}
+
+	for _, test := range tests {
+		testSetRef(t, r, "refs/heads/feature", base)
+		ref, err := r.UpdateRefFrom("refs/heads/feature", test.old, test.sha, false)
+		if test.err != nil {
+			if !sameErrorType(err, test.err) {
+				t.Errorf("UpdateRefFrom(%q, %v): expected a %T, got %v", test.old, test.sha, test.err, err)
+			}
+			if sha := testRefSha(t, r, "refs/heads/feature"); sha != base {
+				t.Errorf("UpdateRefFrom(%q, %v): expected the ref to stay at %v, got %v", test.old, test.sha, base, sha)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("UpdateRefFrom(%q, %v): unexpected error %v", test.old, test.sha, err)
+			continue
+		}
+		if ref.Object.Sha != test.sha {
+			t.Errorf("UpdateRefFrom(%q, %v): expected the ref to point to %v, got %v", test.old, test.sha, test.sha, ref.Object.Sha)
+		}
+	}
+}
+
+func TestSetTargetDetectsConcurrentUpdate(t *testing.T) {
+	r, cleanup := newTestRepo(t, map[string]string{"README.md": "readme"})
+	defer cleanup()
+
+	base := testRefSha(t, r, "refs/heads/master")
+	next := testCommit(t, r, base, map[string]string{"README.md": "next"})
+	other := testCommit(t, r, base, map[string]string{"README.md": "other"})
+
+	ref, err := r.repo.LookupReference("refs/heads/master")
+	if err != nil {
+		t.Fatalf("Error looking up master: %v", err)
+	}
+	testSetRef(t, r, "refs/heads/master", other.Sha)
+
+	if _, err := ref.SetTarget(next.id, r.signature(), ""); !git.IsErrorCode(err, errModified) {
+		t.Errorf("Expected a stale reference update to fail with GIT_EMODIFIED, got %v", err)
+	}
+	if sha := testRefSha(t, r, "refs/heads/master"); sha != other.Sha {
+		t.Errorf("Expected master to stay at %v, got %v", other.Sha, sha)
+	}
+}
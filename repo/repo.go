@@ -2,6 +2,7 @@ package repo
 
 import (
 	"fmt"
+	"strconv"
 
 	"gopkg.in/libgit2/git2go.v22"
 )
@@ -17,6 +18,28 @@ type ForbiddenError struct {
 	msg string
 }
 
+// ConflictError indicates that the action conflicts with the current state of the repo
+type ConflictError struct {
+	msg string
+}
+
+// NonFastForwardError indicates that a ref update would discard commits
+type NonFastForwardError struct {
+	Ref     string
+	Current string
+	Sha     string
+}
+
+// InvalidShaError indicates that a string is not a valid object id
+type InvalidShaError struct {
+	sha string
+}
+
+// InvalidModeError indicates that a tree entry has an unsupported file mode
+type InvalidModeError struct {
+	mode string
+}
+
 func (e *NotFoundError) Error() string {
 	return fmt.Sprintf("No %v with id %v found", e.object, e.id)
 }
@@ -25,11 +48,52 @@ func (e *ForbiddenError) Error() string {
 	return e.msg
 }
 
+func (e *ConflictError) Error() string {
+	return e.msg
+}
+
+func (e *NonFastForwardError) Error() string {
+	return fmt.Sprintf("Update of %v is not a fast forward: %v is not a descendant of %v", e.Ref, e.Sha, e.Current)
+}
+
+func (e *InvalidShaError) Error() string {
+	return fmt.Sprintf("Invalid sha: %v", e.sha)
+}
+
+func (e *InvalidModeError) Error() string {
+	return fmt.Sprintf("Invalid file mode: %v", e.mode)
+}
+
+// parseOid parses a sha into an object id
+func parseOid(sha string) (*git.Oid, error) {
+	oid, err := git.NewOid(sha)
+	if err != nil {
+		return nil, &InvalidShaError{sha: sha}
+	}
+	return oid, nil
+}
+
+// parseMode parses a tree entry mode (in the decimal format used by TreeEntry)
+func parseMode(mode string) (int, error) {
+	filemode, err := strconv.Atoi(mode)
+	if err != nil {
+		return 0, &InvalidModeError{mode: mode}
+	}
+
+	switch git.Filemode(filemode) {
+	case git.FilemodeTree, git.FilemodeBlob, git.FilemodeBlobExecutable, git.FilemodeLink, git.FilemodeCommit:
+		return filemode, nil
+	}
+	return 0, &InvalidModeError{mode: mode}
+}
+
 // Repo represents the github repo we want to operate on
 type Repo struct {
-	repo *git.Repository
-	user User
-	sync bool
+	repo        *git.Repository
+	user        User
+	sync        bool
+	protections []*BranchProtection
+	branches    []string
 }
 
 // User is the main user object for the API.
@@ -37,6 +101,8 @@ type User interface {
 	Name() string
 	Email() string
 	HasPermission(string, string) bool
+	HasRole(string) bool
+	ReadOnly() bool
 }
 
 // Open opens a repository
@@ -48,3 +114,8 @@ func Open(user User, path string, sync bool) (*Repo, error) {
 
 	return &Repo{repo: repo, user: user, sync: sync}, nil
 }
+
+// ReadOnly checks if the repo user only has read access
+func (r *Repo) ReadOnly() bool {
+	return r.user.ReadOnly()
+}
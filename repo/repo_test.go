@@ -0,0 +1,158 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"gopkg.in/libgit2/git2go.v22"
+)
+
+// testUser is a repo user that is allowed everything except the changes
+// listed in denied (as "action:path")
+type testUser struct {
+	name     string
+	email    string
+	roles    []string
+	denied   []string
+	readOnly bool
+}
+
+func (u *testUser) Name() string {
+	return u.name
+}
+
+func (u *testUser) Email() string {
+	return u.email
+}
+
+func (u *testUser) HasPermission(action, pathname string) bool {
+	for _, rule := range u.denied {
+		if rule == action+":"+pathname {
+			return false
+		}
+	}
+	return true
+}
+
+func (u *testUser) HasRole(role string) bool {
+	for _, r := range u.roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *testUser) ReadOnly() bool {
+	return u.readOnly
+}
+
+func newTestUser() *testUser {
+	return &testUser{name: "Test User", email: "test@example.com"}
+}
+
+// newTestRepo creates a bare repository where master has a single commit
+// with files (paths mapped to contents). Call the returned function to remove it.
+func newTestRepo(t *testing.T, files map[string]string) (*Repo, func()) {
+	dir, err := ioutil.TempDir("", "netlify-git-api-test")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	gitRepo, err := git.InitRepository(dir, true)
+	if err != nil {
+		cleanup()
+		t.Fatalf("Error creating repository: %v", err)
+	}
+
+	r := &Repo{repo: gitRepo, user: newTestUser()}
+	commit := testCommit(t, r, "", files)
+	testSetRef(t, r, "refs/heads/master", commit.Sha)
+
+	return r, cleanup
+}
+
+// testCommit commits files on top of parent (a commit sha or empty for a root
+// commit) without moving any ref. An empty content removes the file.
+func testCommit(t *testing.T, r *Repo, parent string, files map[string]string) *Commit {
+	var treeSha string
+	parents := []string{}
+	if parent != "" {
+		commit, err := r.GetCommit(parent)
+		if err != nil {
+			t.Fatalf("Error looking up parent %v: %v", parent, err)
+		}
+		treeSha = commit.Tree.Sha
+		parents = append(parents, parent)
+	}
+
+	for pathname, content := range files {
+		var sha string
+		if content != "" {
+			blob, err := r.PutBlob(strings.NewReader(content))
+			if err != nil {
+				t.Fatalf("Error writing blob for %v: %v", pathname, err)
+			}
+			sha = blob.Sha
+		}
+		tree, err := r.UpdateTreePath(treeSha, pathname, sha, "")
+		if err != nil {
+			t.Fatalf("Error updating %v: %v", pathname, err)
+		}
+		treeSha = tree.Sha
+	}
+
+	if treeSha == "" {
+		tree, err := r.CreateTree("", nil)
+		if err != nil {
+			t.Fatalf("Error creating empty tree: %v", err)
+		}
+		treeSha = tree.Sha
+	}
+
+	commit, err := r.CreateCommit(treeSha, "Test commit", parents)
+	if err != nil {
+		t.Fatalf("Error creating commit: %v", err)
+	}
+	return commit
+}
+
+// testSetRef points a ref to a sha, bypassing all permission checks
+func testSetRef(t *testing.T, r *Repo, name, sha string) {
+	oid, err := parseOid(sha)
+	if err != nil {
+		t.Fatalf("Error parsing %v: %v", sha, err)
+	}
+	if _, err := r.repo.CreateReference(name, oid, true, r.signature(), ""); err != nil {
+		t.Fatalf("Error setting %v: %v", name, err)
+	}
+}
+
+// testRefSha returns the sha a ref points to
+func testRefSha(t *testing.T, r *Repo, name string) string {
+	ref, err := r.GetRef(name)
+	if err != nil {
+		t.Fatalf("Error looking up %v: %v", name, err)
+	}
+	return ref.Object.Sha
+}
+
+// testFileContent reads a file at a revision
+func testFileContent(t *testing.T, r *Repo, rev, pathname string) string {
+	file, err := r.GetFileAt(rev, pathname)
+	if err != nil {
+		t.Fatalf("Error reading %v at %v: %v", pathname, rev, err)
+	}
+	blob, err := r.GetBlob(file.Sha)
+	if err != nil {
+		t.Fatalf("Error reading blob %v: %v", file.Sha, err)
+	}
+	data, err := ioutil.ReadAll(blob)
+	if err != nil {
+		t.Fatalf("Error reading blob %v: %v", file.Sha, err)
+	}
+	return string(data)
+}
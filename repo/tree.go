@@ -2,7 +2,7 @@ package repo
 
 import (
 	"fmt"
-	"strconv"
+	"strings"
 
 	"gopkg.in/libgit2/git2go.v22"
 )
@@ -46,7 +46,7 @@ func (r *Repo) newTreeEntry(entry *git.TreeEntry) *TreeEntry {
 
 // GetTree returns a tree from the repository from a sha
 func (r *Repo) GetTree(sha string) (*Tree, error) {
-	oid, err := git.NewOid(sha)
+	oid, err := parseOid(sha)
 	if err != nil {
 		return nil, err
 	}
@@ -80,7 +80,7 @@ func (r *Repo) CreateTree(baseSha string, entries []*TreeEntry) (*Tree, error) {
 	defer builder.Free()
 
 	if baseSha != "" {
-		baseID, err := git.NewOid(baseSha)
+		baseID, err := parseOid(baseSha)
 		if err != nil {
 			return nil, err
 		}
@@ -101,11 +101,11 @@ func (r *Repo) CreateTree(baseSha string, entries []*TreeEntry) (*Tree, error) {
 	}
 
 	for _, entry := range entries {
-		oid, err := git.NewOid(entry.Sha)
+		oid, err := parseOid(entry.Sha)
 		if err != nil {
 			return nil, err
 		}
-		mode, err := strconv.Atoi(entry.Mode)
+		mode, err := parseMode(entry.Mode)
 		if err != nil {
 			return nil, err
 		}
@@ -122,3 +122,155 @@ func (r *Repo) CreateTree(baseSha string, entries []*TreeEntry) (*Tree, error) {
 
 	return r.GetTree(oid.String())
 }
+
+// GetTreeEntry looks up the entry at pathname within the tree with treeSha
+func (r *Repo) GetTreeEntry(treeSha, pathname string) (*TreeEntry, error) {
+	oid, err := parseOid(treeSha)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := r.repo.LookupTree(oid)
+	if err != nil {
+		return nil, &NotFoundError{id: treeSha, object: "Tree"}
+	}
+
+	entry, err := tree.EntryByPath(pathname)
+	if err != nil {
+		return nil, &NotFoundError{id: pathname, object: "File or Dir"}
+	}
+
+	return r.newTreeEntry(entry), nil
+}
+
+// UpdateTreePath creates a new tree based on the tree with baseSha where the
+// entry at pathname points to sha. Missing intermediate trees are created and
+// trees left empty are dropped. An empty sha removes the entry and an empty
+// mode defaults to a regular file.
+func (r *Repo) UpdateTreePath(baseSha, pathname, sha, mode string) (*Tree, error) {
+	var base *git.Tree
+	if baseSha != "" {
+		baseID, err := parseOid(baseSha)
+		if err != nil {
+			return nil, err
+		}
+
+		base, err = r.repo.LookupTree(baseID)
+		if err != nil {
+			return nil, &NotFoundError{id: baseSha, object: "Base Tree"}
+		}
+	}
+
+	var oid *git.Oid
+	if sha != "" {
+		var err error
+		oid, err = parseOid(sha)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	filemode := int(git.FilemodeBlob)
+	if mode != "" {
+		var err error
+		filemode, err = parseMode(mode)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	segments := strings.Split(strings.Trim(pathname, "/"), "/")
+	treeID, err := r.updateTreePath(base, segments, pathname, oid, filemode)
+	if err != nil {
+		return nil, err
+	}
+
+	if treeID == nil {
+		// The last entry was removed, write out an empty root tree
+		builder, err := r.repo.TreeBuilder()
+		if err != nil {
+			return nil, err
+		}
+		defer builder.Free()
+
+		treeID, err = builder.Write()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return r.GetTree(treeID.String())
+}
+
+// updateTreePath writes a copy of base with the change applied and returns the
+// id of the new tree, or nil if the tree ended up empty
+func (r *Repo) updateTreePath(base *git.Tree, segments []string, pathname string, oid *git.Oid, mode int) (*git.Oid, error) {
+	builder, err := r.repo.TreeBuilder()
+	if err != nil {
+		return nil, err
+	}
+	defer builder.Free()
+
+	var count uint64
+	if base != nil {
+		count = base.EntryCount()
+		var i uint64
+		for i = 0; i < count; i++ {
+			entry := base.EntryByIndex(i)
+			err = builder.Insert(entry.Name, entry.Id, int(entry.Filemode))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	name := segments[0]
+	var existing *git.TreeEntry
+	if base != nil {
+		existing = base.EntryByName(name)
+	}
+
+	if len(segments) > 1 {
+		var subtree *git.Tree
+		if existing != nil {
+			if existing.Type != git.ObjectTree {
+				return nil, &ConflictError{msg: fmt.Sprintf("%v is not a directory", name)}
+			}
+			subtree, err = r.repo.LookupTree(existing.Id)
+			if err != nil {
+				return nil, err
+			}
+		} else if oid == nil {
+			return nil, &NotFoundError{id: pathname, object: "File"}
+		}
+
+		oid, err = r.updateTreePath(subtree, segments[1:], pathname, oid, mode)
+		if err != nil {
+			return nil, err
+		}
+		mode = int(git.FilemodeTree)
+	}
+
+	if oid == nil {
+		if existing == nil {
+			return nil, &NotFoundError{id: pathname, object: "File"}
+		}
+		if err := builder.Remove(name); err != nil {
+			return nil, err
+		}
+		count--
+	} else {
+		if err := builder.Insert(name, oid, mode); err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			count++
+		}
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+
+	return builder.Write()
+}
@@ -0,0 +1,121 @@
+package repo
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUpdateTreePath(t *testing.T) {
+	r, cleanup := newTestRepo(t, map[string]string{
+		"README.md":          "readme",
+		"content/post.md":    "post",
+		"content/a/b/doc.md": "doc",
+	})
+	defer cleanup()
+
+	base := testRefSha(t, r, "refs/heads/master")
+	commit, err := r.GetCommit(base)
+	if err != nil {
+		t.Fatalf("Error reading commit: %v", err)
+	}
+	blob, err := r.PutBlob(strings.NewReader("new"))
+	if err != nil {
+		t.Fatalf("Error writing blob: %v", err)
+	}
+
+	tests := []struct {
+		path    string
+		sha     string
+		mode    string
+		exists  []string
+		missing []string
+		err     interface{}
+	}{
+		{path: "new.md", sha: blob.Sha, exists: []string{"new.md", "README.md", "content/post.md"}},
+		{path: "README.md", sha: blob.Sha, exists: []string{"README.md"}},
+		{path: "deep/new/dir/file.md", sha: blob.Sha, exists: []string{"deep/new/dir/file.md", "content/a/b/doc.md"}},
+		{path: "/content/new.md", sha: blob.Sha, exists: []string{"content/new.md", "content/post.md"}},
+		{path: "content/post.md", sha: "", exists: []string{"content/a/b/doc.md"}, missing: []string{"content/post.md"}},
+		{path: "content/a/b/doc.md", sha: "", exists: []string{"content/post.md"}, missing: []string{"content/a"}},
+		{path: "missing.md", sha: "", err: &NotFoundError{}},
+		{path: "nope/missing.md", sha: "", err: &NotFoundError{}},
+		{path: "README.md/file.md", sha: blob.Sha, err: &ConflictError{}},
+		{path: "bad.md", sha: "not-a-sha", err: &InvalidShaError{}},
+		{path: "bad.md", sha: blob.Sha, mode: "123", err: &InvalidModeError{}},
+	}
+
+	for _, test := range tests {
+		tree, err := r.UpdateTreePath(commit.Tree.Sha, test.path, test.sha, test.mode)
+		if test.err != nil {
+			if !sameErrorType(err, test.err) {
+				t.Errorf("UpdateTreePath(%q, %q): expected a %T, got %v", test.path, test.sha, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("UpdateTreePath(%q, %q): unexpected error %v", test.path, test.sha, err)
+			continue
+		}
+
+		for _, pathname := range test.exists {
+			entry, err := r.GetTreeEntry(tree.Sha, pathname)
+			if err != nil {
+				t.Errorf("UpdateTreePath(%q, %q): expected %v to exist, got %v", test.path, test.sha, pathname, err)
+				continue
+			}
+			if pathname == strings.Trim(test.path, "/") && entry.Sha != test.sha {
+				t.Errorf("UpdateTreePath(%q, %q): expected %v to point to %v, got %v", test.path, test.sha, pathname, test.sha, entry.Sha)
+			}
+		}
+		for _, pathname := range test.missing {
+			if _, err := r.GetTreeEntry(tree.Sha, pathname); err == nil {
+				t.Errorf("UpdateTreePath(%q, %q): expected %v to be removed", test.path, test.sha, pathname)
+			}
+		}
+	}
+}
+
+func TestUpdateTreePathRemovesLastEntry(t *testing.T) {
+	r, cleanup := newTestRepo(t, map[string]string{"dir/only.md": "only"})
+	defer cleanup()
+
+	commit, err := r.GetCommit(testRefSha(t, r, "refs/heads/master"))
+	if err != nil {
+		t.Fatalf("Error reading commit: %v", err)
+	}
+
+	tree, err := r.UpdateTreePath(commit.Tree.Sha, "dir/only.md", "", "")
+	if err != nil {
+		t.Fatalf("Error removing the last file: %v", err)
+	}
+	if len(tree.Tree) != 0 {
+		t.Errorf("Expected an empty root tree, got %v entries", len(tree.Tree))
+	}
+}
+
+func TestUpdateTreePathKeepsMode(t *testing.T) {
+	r, cleanup := newTestRepo(t, nil)
+	defer cleanup()
+
+	blob, err := r.PutBlob(strings.NewReader("#!/bin/sh"))
+	if err != nil {
+		t.Fatalf("Error writing blob: %v", err)
+	}
+	tree, err := r.UpdateTreePath("", "bin/run", blob.Sha, "33261")
+	if err != nil {
+		t.Fatalf("Error adding executable: %v", err)
+	}
+	entry, err := r.GetTreeEntry(tree.Sha, "bin/run")
+	if err != nil {
+		t.Fatalf("Error looking up entry: %v", err)
+	}
+	if entry.Mode != "33261" {
+		t.Errorf("Expected mode 33261, got %v", entry.Mode)
+	}
+}
+
+// sameErrorType checks if err has the same concrete type as expected
+func sameErrorType(err error, expected interface{}) bool {
+	return err != nil && reflect.TypeOf(err) == reflect.TypeOf(expected)
+}
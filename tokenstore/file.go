@@ -0,0 +1,120 @@
+package tokenstore
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileStore keeps tokens in a JSON file so they survive restarts
+type FileStore struct {
+	mutex  sync.Mutex
+	path   string
+	tokens map[string]*Token
+}
+
+// NewFileStore opens a file backed store, creating the file on the first write
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, tokens: map[string]*Token{}}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+
+	tokens := []*Token{}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		if !token.Expired() {
+			s.tokens[token.Hash] = token
+		}
+	}
+	return s, nil
+}
+
+// Save stores a new token value
+func (s *FileStore) Save(value string, token *Token) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	token.Hash = Hash(value)
+	s.tokens[token.Hash] = token
+	return s.write()
+}
+
+// Get returns the token for a value or nil if unknown or expired
+func (s *FileStore) Get(value string) (*Token, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	token, ok := s.tokens[Hash(value)]
+	if !ok {
+		return nil, nil
+	}
+	if token.Expired() {
+		delete(s.tokens, token.Hash)
+		return nil, s.write()
+	}
+	return token, nil
+}
+
+// Delete revokes a token
+func (s *FileStore) Delete(value string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.tokens, Hash(value))
+	return s.write()
+}
+
+// DeleteUser revokes all tokens of a user
+func (s *FileStore) DeleteUser(userID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for hash, token := range s.tokens {
+		if token.UserID == userID {
+			delete(s.tokens, hash)
+		}
+	}
+	return s.write()
+}
+
+// write replaces the file with the current unexpired tokens. Must be called
+// with the mutex held.
+func (s *FileStore) write() error {
+	tokens := []*Token{}
+	for hash, token := range s.tokens {
+		if token.Expired() {
+			delete(s.tokens, hash)
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,62 @@
+package tokenstore
+
+import "sync"
+
+// MemoryStore keeps tokens in memory until the process exits
+type MemoryStore struct {
+	mutex  sync.Mutex
+	tokens map[string]*Token
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tokens: map[string]*Token{}}
+}
+
+// Save stores a new token value
+func (s *MemoryStore) Save(value string, token *Token) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	token.Hash = Hash(value)
+	s.tokens[token.Hash] = token
+	return nil
+}
+
+// Get returns the token for a value or nil if unknown or expired
+func (s *MemoryStore) Get(value string) (*Token, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	token, ok := s.tokens[Hash(value)]
+	if !ok {
+		return nil, nil
+	}
+	if token.Expired() {
+		delete(s.tokens, token.Hash)
+		return nil, nil
+	}
+	return token, nil
+}
+
+// Delete revokes a token
+func (s *MemoryStore) Delete(value string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.tokens, Hash(value))
+	return nil
+}
+
+// DeleteUser revokes all tokens of a user
+func (s *MemoryStore) DeleteUser(userID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for hash, token := range s.tokens {
+		if token.UserID == userID {
+			delete(s.tokens, hash)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package tokenstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+const (
+	// Access tokens authorize API requests
+	Access = "access"
+	// Refresh tokens can be exchanged for a new access token
+	Refresh = "refresh"
+)
+
+// Token is a token issued to a user. Only a hash of the token value is kept
+// in the store.
+type Token struct {
+	Hash      string    `json:"hash"`
+	Kind      string    `json:"kind"`
+	UserID    string    `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Store keeps track of issued tokens
+type Store interface {
+	// Save stores a new token value
+	Save(value string, token *Token) error
+	// Get returns the token for a value or nil if unknown or expired
+	Get(value string) (*Token, error)
+	// Delete revokes a token
+	Delete(value string) error
+	// DeleteUser revokes all tokens of a user
+	DeleteUser(userID string) error
+}
+
+// New creates a token for a user that expires after ttl
+func New(kind, userID string, ttl time.Duration) *Token {
+	return &Token{Kind: kind, UserID: userID, ExpiresAt: time.Now().Add(ttl)}
+}
+
+// Expired checks if the token is no longer valid
+func (t *Token) Expired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
+
+// Hash returns the hash a token value is stored under
+func Hash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,134 @@
+package tokenstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testStores returns a memory and a file store and a function removing the
+// file store's directory
+func testStores(t *testing.T) (map[string]Store, func()) {
+	dir, err := ioutil.TempDir("", "tokenstore-test")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	fileStore, err := NewFileStore(filepath.Join(dir, "tokens.json"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Error opening file store: %v", err)
+	}
+	stores := map[string]Store{"memory": NewMemoryStore(), "file": fileStore}
+	return stores, func() { os.RemoveAll(dir) }
+}
+
+func TestStore(t *testing.T) {
+	stores, cleanup := testStores(t)
+	defer cleanup()
+
+	for name, store := range stores {
+		tokens := []struct {
+			value string
+			token *Token
+		}{
+			{value: "access-1", token: New(Access, "user-1", time.Hour)},
+			{value: "refresh-1", token: New(Refresh, "user-1", time.Hour)},
+			{value: "access-2", token: New(Access, "user-2", time.Hour)},
+			{value: "expired", token: New(Access, "user-2", -time.Second)},
+		}
+		for _, token := range tokens {
+			if err := store.Save(token.value, token.token); err != nil {
+				t.Fatalf("%v: error saving %v: %v", name, token.value, err)
+			}
+		}
+
+		tests := []struct {
+			action string
+			value  string
+			kind   string
+			userID string
+		}{
+			{value: "access-1", kind: Access, userID: "user-1"},
+			{value: "refresh-1", kind: Refresh, userID: "user-1"},
+			{value: "access-2", kind: Access, userID: "user-2"},
+			{value: "expired"},
+			{value: "unknown"},
+			{action: "delete", value: "access-1"},
+			{value: "access-1"},
+			{value: "refresh-1", kind: Refresh, userID: "user-1"},
+			{action: "delete user", value: "user-1"},
+			{value: "refresh-1"},
+			{value: "access-2", kind: Access, userID: "user-2"},
+		}
+
+		for _, test := range tests {
+			switch test.action {
+			case "delete":
+				if err := store.Delete(test.value); err != nil {
+					t.Errorf("%v: error deleting %v: %v", name, test.value, err)
+				}
+				continue
+			case "delete user":
+				if err := store.DeleteUser(test.value); err != nil {
+					t.Errorf("%v: error deleting tokens of %v: %v", name, test.value, err)
+				}
+				continue
+			}
+
+			token, err := store.Get(test.value)
+			if err != nil {
+				t.Errorf("%v: Get(%q): unexpected error %v", name, test.value, err)
+				continue
+			}
+			if test.userID == "" {
+				if token != nil {
+					t.Errorf("%v: Get(%q): expected no token, got %v", name, test.value, token)
+				}
+				continue
+			}
+			if token == nil || token.UserID != test.userID || token.Kind != test.kind {
+				t.Errorf("%v: Get(%q): expected a %v token of %v, got %v", name, test.value, test.kind, test.userID, token)
+			}
+		}
+	}
+}
+
+func TestFileStorePersists(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tokenstore-test")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tokens.json")
+
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("Error opening file store: %v", err)
+	}
+	store.Save("kept", New(Refresh, "user-1", time.Hour))
+	store.Save("expired", New(Refresh, "user-1", -time.Second))
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Error reading store: %v", err)
+	}
+	for _, value := range []string{"kept", "expired"} {
+		if strings.Contains(string(data), value) {
+			t.Errorf("Expected the token value %q not to be stored in the file", value)
+		}
+	}
+
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("Error reopening file store: %v", err)
+	}
+	if token, _ := reopened.Get("kept"); token == nil || token.UserID != "user-1" {
+		t.Errorf("Expected the token to survive a restart, got %v", token)
+	}
+	if token, _ := reopened.Get("expired"); token != nil {
+		t.Errorf("Expected the expired token to be dropped, got %v", token)
+	}
+}
@@ -1,54 +1,50 @@
 package userdb
 
 import (
-	"io/ioutil"
-	"os"
+	"crypto/sha256"
+	"fmt"
 
 	"github.com/pborman/uuid"
 
 	"golang.org/x/crypto/bcrypt"
-	"gopkg.in/yaml.v2"
 )
 
 // User is a user in the db
 type User struct {
-	ID           string `yaml:"id"`
-	Name         string `yaml:"name"`
-	Email        string `yaml:"email"`
-	PasswordHash string `yaml:"hash"`
+	ID           string      `yaml:"id" json:"id"`
+	Name         string      `yaml:"name" json:"name"`
+	Email        string      `yaml:"email" json:"email"`
+	PasswordHash string      `yaml:"hash" json:"hash"`
+	TOTPSecret   string      `yaml:"totp_secret,omitempty" json:"totp_secret,omitempty"`
+	Roles        []string    `yaml:"roles,omitempty" json:"roles,omitempty"`
+	Groups       []string    `yaml:"groups,omitempty" json:"groups,omitempty"`
+	Permissions  Permissions `yaml:"permissions,omitempty" json:"permissions,omitempty"`
+	Keys         []APIKey    `yaml:"keys,omitempty" json:"keys,omitempty"`
+	Disabled     bool        `yaml:"disabled,omitempty" json:"disabled,omitempty"`
 }
 
-// UserDB is the full set of users
+// UserDB is the full set of users, roles and groups. It's the format of the
+// YAML and JSON stores and what permission checks look roles and groups up in.
 type UserDB struct {
-	dbPath string
-	Users  []User `yaml:"users"`
+	Roles  []Role  `yaml:"roles,omitempty" json:"roles,omitempty"`
+	Groups []Group `yaml:"groups,omitempty" json:"groups,omitempty"`
+	Users  []User  `yaml:"users" json:"users"`
 }
 
-// Read a userDB from a filepath
-func Read(dbPath string) (*UserDB, error) {
-	db := &UserDB{dbPath: dbPath}
-	data, err := ioutil.ReadFile(dbPath)
-	if err != nil && !os.IsNotExist(err) {
-		return nil, err
-	}
-	err = yaml.Unmarshal(data, db)
-	return db, err
+func lockPath(dbPath string) string {
+	return dbPath + ".lock"
 }
 
-// Write the userDB
-func (db *UserDB) Write() error {
-	yml, err := yaml.Marshal(&db)
-	if err != nil {
-		return err
-	}
-	return ioutil.WriteFile(db.dbPath, yml, 0644)
+func checksum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
 }
 
 // LookupByEmail a user by email
 func (db *UserDB) LookupByEmail(email string) *User {
-	for _, user := range db.Users {
-		if user.Email == email {
-			return &user
+	for i := range db.Users {
+		if db.Users[i].Email == email {
+			return &db.Users[i]
 		}
 	}
 	return nil
@@ -56,30 +52,46 @@ func (db *UserDB) LookupByEmail(email string) *User {
 
 // Get a user by ID
 func (db *UserDB) Get(id string) *User {
-	for _, user := range db.Users {
-		if user.ID == id {
-			return &user
+	for i := range db.Users {
+		if db.Users[i].ID == id {
+			return &db.Users[i]
 		}
 	}
 	return nil
 }
 
-// Add a new user to the db
+// NewUser creates a user with a new id and a password
+func NewUser(email, name, pw string) (*User, error) {
+	user := &User{ID: uuid.New(), Name: name, Email: email}
+	if err := user.SetPassword(pw); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Add a new user to the db. Fails if a user with the email already exists.
 func (db *UserDB) Add(email, name, pw string) (*User, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.DefaultCost)
+	if db.LookupByEmail(email) != nil {
+		return nil, fmt.Errorf("A user with the email %v already exists", email)
+	}
+
+	user, err := NewUser(email, name, pw)
 	if err != nil {
 		return nil, err
 	}
+	db.Users = append(db.Users, *user)
 
-	user := db.LookupByEmail(email)
-	if user == nil {
-		db.Users = append(db.Users, User{ID: uuid.New(), Name: name, Email: email, PasswordHash: string(hash)})
-	} else {
-		user.Name = name
-		user.PasswordHash = string(hash)
-	}
+	return &db.Users[len(db.Users)-1], nil
+}
 
-	return user, nil
+// emailTaken checks if another user has the email of user
+func (db *UserDB) emailTaken(user *User) bool {
+	for i := range db.Users {
+		if db.Users[i].Email == user.Email && db.Users[i].ID != user.ID {
+			return true
+		}
+	}
+	return false
 }
 
 // Delete a user from the db
@@ -93,6 +105,16 @@ func (db *UserDB) Delete(email string) {
 	db.Users = users
 }
 
+// SetPassword replaces the password hash of the user
+func (u *User) SetPassword(pw string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	u.PasswordHash = string(hash)
+	return nil
+}
+
 // Authenticate checks if a password is valid for this user
 func (u *User) Authenticate(pw string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(pw))
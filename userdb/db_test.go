@@ -0,0 +1,181 @@
+package userdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func tempDBPath(t *testing.T, name string) (string, func()) {
+	dir, err := ioutil.TempDir("", "userdb-test")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	return filepath.Join(dir, name), func() { os.RemoveAll(dir) }
+}
+
+func TestStoreFiles(t *testing.T) {
+	for _, name := range []string{"users.yml", "users.json", "users.db"} {
+		path, cleanup := tempDBPath(t, name)
+
+		store := NewStore(path)
+		user, err := NewUser("test@example.com", "Test User", "secret")
+		if err != nil {
+			t.Fatalf("%v: error creating user: %v", name, err)
+		}
+		if err := store.AddUser(user); err != nil {
+			t.Fatalf("%v: error adding user: %v", name, err)
+		}
+		err = store.Update(func(db *UserDB) error {
+			db.AddRole("writer", Permissions{"*": {Allow: []string{"content/**"}}})
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%v: error adding role: %v", name, err)
+		}
+
+		if runtime.GOOS != "windows" {
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("%v: error reading file info: %v", name, err)
+			}
+			if info.Mode().Perm() != 0600 {
+				t.Errorf("%v: expected the db to only be readable by its owner, got %v", name, info.Mode().Perm())
+			}
+		}
+
+		read, err := NewStore(path).Load()
+		if err != nil {
+			t.Fatalf("%v: error reading db: %v", name, err)
+		}
+		found := read.LookupByEmail("test@example.com")
+		if found == nil || found.Name != "Test User" || !found.Authenticate("secret") {
+			t.Errorf("%v: expected the user to be written, got %v", name, found)
+		}
+		if read.Role("writer") == nil || len(read.Roles) != 1 {
+			t.Errorf("%v: expected the role to be written, got %v", name, read.Roles)
+		}
+		cleanup()
+	}
+}
+
+func TestStoreSeesOtherWriters(t *testing.T) {
+	for _, name := range []string{"users.yml", "users.db"} {
+		path, cleanup := tempDBPath(t, name)
+		first := NewStore(path)
+		second := NewStore(path)
+
+		if user, err := first.LookupByEmail("user1@example.com"); err != nil || user != nil {
+			t.Fatalf("%v: expected no user, got %v, %v", name, user, err)
+		}
+		for i, store := range []Store{first, second} {
+			user, _ := NewUser(fmt.Sprintf("user%v@example.com", i), "User", "secret")
+			if err := store.AddUser(user); err != nil {
+				t.Fatalf("%v: error adding user: %v", name, err)
+			}
+		}
+
+		for _, store := range []Store{first, second} {
+			db, err := store.Load()
+			if err != nil {
+				t.Fatalf("%v: error loading db: %v", name, err)
+			}
+			if len(db.Users) != 2 {
+				t.Errorf("%v: expected both writes to be stored, got %v", name, db.Users)
+			}
+			for _, user := range db.Users {
+				if found, err := store.LookupByEmail(user.Email); err != nil || found == nil {
+					t.Errorf("%v: expected to find %v, got %v, %v", name, user.Email, found, err)
+				}
+			}
+		}
+		cleanup()
+	}
+}
+
+func TestUpdateLocks(t *testing.T) {
+	path, cleanup := tempDBPath(t, "users.yml")
+	defer cleanup()
+
+	locked := make(chan bool)
+	release := make(chan bool)
+	done := make(chan error)
+	go func() {
+		done <- NewStore(path).Update(func(db *UserDB) error {
+			locked <- true
+			<-release
+			_, err := db.Add("first@example.com", "First", "secret")
+			return err
+		})
+	}()
+	<-locked
+
+	added := make(chan error)
+	go func() {
+		user, _ := NewUser("second@example.com", "Second", "secret")
+		added <- NewStore(path).AddUser(user)
+	}()
+
+	select {
+	case <-added:
+		t.Fatalf("Expected the second write to wait for the lock")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	release <- true
+	if err := <-done; err != nil {
+		t.Fatalf("Error in the first write: %v", err)
+	}
+	select {
+	case err := <-added:
+		if err != nil {
+			t.Fatalf("Error in the second write: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the second write to get the lock after the first")
+	}
+
+	db, err := NewStore(path).Load()
+	if err != nil {
+		t.Fatalf("Error loading db: %v", err)
+	}
+	if db.LookupByEmail("first@example.com") == nil || db.LookupByEmail("second@example.com") == nil {
+		t.Errorf("Expected both users to be stored, got %v", db.Users)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	db := &UserDB{}
+	user, err := db.Add("test@example.com", "Test User", "secret")
+	if err != nil {
+		t.Fatalf("Error adding user: %v", err)
+	}
+	other, err := db.Add("other@example.com", "Other User", "secret")
+	if err != nil {
+		t.Fatalf("Error adding user: %v", err)
+	}
+	if _, err := db.Add("test@example.com", "Duplicate", "secret"); err == nil {
+		t.Errorf("Expected an error adding a duplicate email")
+	}
+
+	if db.Get(user.ID) == nil || db.Get(user.ID).Email != "test@example.com" {
+		t.Errorf("Expected to find the user by id")
+	}
+	if db.emailTaken(db.Get(user.ID)) {
+		t.Errorf("Expected the email of a user not to be taken by themselves")
+	}
+	other.Email = "test@example.com"
+	if !db.emailTaken(other) {
+		t.Errorf("Expected the email of another user to be taken")
+	}
+	other.Email = "other@example.com"
+
+	db.Delete("test@example.com")
+	if db.LookupByEmail("test@example.com") != nil || len(db.Users) != 1 {
+		t.Errorf("Expected the user to be deleted, got %v", db.Users)
+	}
+}
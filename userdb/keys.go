@@ -0,0 +1,122 @@
+package userdb
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pborman/uuid"
+)
+
+// KeyPrefix starts every API key so they can be told apart from access tokens
+const KeyPrefix = "ngk_"
+
+// APIKey is a long lived key for automation. Only the hash of the key is
+// stored, the key itself is shown once when it's created.
+type APIKey struct {
+	ID        string     `yaml:"id" json:"id"`
+	Name      string     `yaml:"name" json:"name"`
+	Hash      string     `yaml:"hash" json:"hash"`
+	Scope     KeyScope   `yaml:"scope" json:"scope"`
+	CreatedAt time.Time  `yaml:"created_at" json:"created_at"`
+	ExpiresAt *time.Time `yaml:"expires_at,omitempty" json:"expires_at,omitempty"`
+}
+
+// KeyScope limits what a key can do on top of the permissions of its user.
+// Keys are read only unless Write is set. Paths are patterns (see MatchPath)
+// of the files the key may change and Branches are patterns of the branches
+// it may update, empty means no further restriction. Paths and Branches only
+// limit writes, a key can read every file and branch its user can read.
+type KeyScope struct {
+	Write    bool     `yaml:"write,omitempty" json:"write,omitempty"`
+	Paths    []string `yaml:"paths,omitempty" json:"paths,omitempty"`
+	Branches []string `yaml:"branches,omitempty" json:"branches,omitempty"`
+}
+
+// Expired checks if the key is past its expiry
+func (k *APIKey) Expired() bool {
+	return k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt)
+}
+
+// AllowsPath checks if the scope of the key covers a path
+func (s *KeyScope) AllowsPath(pathname string) bool {
+	return len(s.Paths) == 0 || matchAny(s.Paths, pathname)
+}
+
+// CreateKey adds a new API key to the user and returns the key. A ttl of 0
+// creates a key that doesn't expire.
+func (u *User) CreateKey(name string, scope KeyScope, ttl time.Duration) (string, *APIKey, error) {
+	if u.Key(name) != nil {
+		return "", nil, fmt.Errorf("User %v already has a key named %v", u.Email, name)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	value := KeyPrefix + hex.EncodeToString(secret)
+
+	key := APIKey{ID: uuid.New(), Name: name, Hash: hashKey(value), Scope: scope, CreatedAt: time.Now().UTC()}
+	if ttl > 0 {
+		expiresAt := key.CreatedAt.Add(ttl)
+		key.ExpiresAt = &expiresAt
+	}
+	u.Keys = append(u.Keys, key)
+
+	return value, &u.Keys[len(u.Keys)-1], nil
+}
+
+// Key looks up a key of the user by name
+func (u *User) Key(name string) *APIKey {
+	for i := range u.Keys {
+		if u.Keys[i].Name == name {
+			return &u.Keys[i]
+		}
+	}
+	return nil
+}
+
+// RevokeKey removes a key by name, returns false if the user has no such key
+func (u *User) RevokeKey(name string) bool {
+	keys := []APIKey{}
+	for _, key := range u.Keys {
+		if key.Name != name {
+			keys = append(keys, key)
+		}
+	}
+	revoked := len(keys) != len(u.Keys)
+	u.Keys = keys
+	return revoked
+}
+
+// LookupKey finds the user and the key for an API key in a store. Returns
+// nil if the key is unknown or expired.
+func LookupKey(store Store, value string) (*User, *APIKey, error) {
+	if !strings.HasPrefix(value, KeyPrefix) {
+		return nil, nil, nil
+	}
+
+	hash := hashKey(value)
+	user, err := store.LookupByKeyHash(hash)
+	if err != nil || user == nil {
+		return nil, nil, err
+	}
+	for i := range user.Keys {
+		key := &user.Keys[i]
+		if key.Hash == hash {
+			if key.Expired() {
+				return nil, nil, nil
+			}
+			return user, key, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+func hashKey(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
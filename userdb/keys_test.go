@@ -0,0 +1,105 @@
+package userdb
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateKey(t *testing.T) {
+	user := &User{Email: "bot@example.com"}
+	value, key, err := user.CreateKey("importer", KeyScope{Write: true}, 0)
+	if err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	if !strings.HasPrefix(value, KeyPrefix) {
+		t.Errorf("Expected the key to start with %v, got %v", KeyPrefix, value)
+	}
+	if key.Hash == "" || strings.Contains(key.Hash, value) {
+		t.Errorf("Expected only a hash of the key to be stored, got %v", key.Hash)
+	}
+	if key.ExpiresAt != nil {
+		t.Errorf("Expected a key without expiry, got %v", key.ExpiresAt)
+	}
+	if _, _, err := user.CreateKey("importer", KeyScope{}, 0); err == nil {
+		t.Errorf("Expected an error for a duplicate key name")
+	}
+
+	_, expiring, err := user.CreateKey("temporary", KeyScope{}, time.Hour)
+	if err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	if expiring.ExpiresAt == nil || expiring.Expired() {
+		t.Errorf("Expected a key that expires in an hour, got %v", expiring.ExpiresAt)
+	}
+
+	if !user.RevokeKey("importer") || user.Key("importer") != nil {
+		t.Errorf("Expected the key to be revoked")
+	}
+	if user.RevokeKey("importer") {
+		t.Errorf("Expected revoking a missing key to fail")
+	}
+}
+
+func TestLookupKey(t *testing.T) {
+	db := &UserDB{Users: []User{{ID: "1", Email: "bot@example.com"}, {ID: "2", Email: "other@example.com"}}}
+	value, _, err := db.Users[0].CreateKey("importer", KeyScope{}, 0)
+	if err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	expired, key, err := db.Users[1].CreateKey("old", KeyScope{}, time.Hour)
+	if err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	past := time.Now().Add(-time.Minute)
+	key.ExpiresAt = &past
+
+	tests := []struct {
+		value  string
+		userID string
+	}{
+		{value: value, userID: "1"},
+		{value: expired},
+		{value: KeyPrefix + "unknown"},
+		{value: strings.TrimPrefix(value, KeyPrefix)},
+		{value: ""},
+	}
+
+	store := NewMemoryStore(db)
+	for _, test := range tests {
+		user, key, err := LookupKey(store, test.value)
+		if err != nil {
+			t.Errorf("LookupKey(%q): unexpected error %v", test.value, err)
+			continue
+		}
+		if test.userID == "" {
+			if user != nil || key != nil {
+				t.Errorf("LookupKey(%q): expected no user, got %v", test.value, user)
+			}
+			continue
+		}
+		if user == nil || user.ID != test.userID || key == nil {
+			t.Errorf("LookupKey(%q): expected user %v, got %v", test.value, test.userID, user)
+		}
+	}
+}
+
+func TestKeyScopeAllowsPath(t *testing.T) {
+	tests := []struct {
+		paths    []string
+		path     string
+		expected bool
+	}{
+		{path: "content/post.md", expected: true},
+		{paths: []string{"content/**"}, path: "content/posts/post.md", expected: true},
+		{paths: []string{"content/**"}, path: "config.yml", expected: false},
+		{paths: []string{"content/*.md", "static/**"}, path: "static/img/logo.png", expected: true},
+	}
+
+	for _, test := range tests {
+		scope := &KeyScope{Paths: test.paths}
+		if allowed := scope.AllowsPath(test.path); allowed != test.expected {
+			t.Errorf("AllowsPath(%v, %q): expected %v, got %v", test.paths, test.path, test.expected, allowed)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package userdb
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Live holds the roles and groups of a running server and can swap in a new
+// version of them when the store changed while the old one is still in use
+type Live struct {
+	store       Store
+	mutex       sync.RWMutex
+	db          *UserDB
+	version     []byte
+	credentials map[string]string
+}
+
+// NewLive loads the roles and groups of a store
+func NewLive(store Store) (*Live, error) {
+	l := &Live{store: store}
+	if _, err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// DB returns the current version of the roles and groups, without users
+func (l *Live) DB() *UserDB {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.db
+}
+
+// Reload loads the store again if it changed and swaps in its roles and
+// groups. Returns the ids of the users that were removed, disabled or whose
+// password changed since the last load. Reload must not be called
+// concurrently with itself.
+func (l *Live) Reload() ([]string, error) {
+	version, err := l.store.Version()
+	if err != nil {
+		return nil, err
+	}
+	if l.DB() != nil && bytes.Equal(version, l.version) {
+		return nil, nil
+	}
+
+	db, err := l.store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	// Password hashes of the users that can log in
+	credentials := map[string]string{}
+	for _, user := range db.Users {
+		if !user.Disabled {
+			credentials[user.ID] = user.PasswordHash
+		}
+	}
+	changed := []string{}
+	for id, hash := range l.credentials {
+		if current, ok := credentials[id]; !ok || current != hash {
+			changed = append(changed, id)
+		}
+	}
+	db.Users = nil
+
+	l.mutex.Lock()
+	l.db = db
+	l.mutex.Unlock()
+	l.version = version
+	l.credentials = credentials
+	return changed, nil
+}
@@ -0,0 +1,86 @@
+package userdb
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestLiveReload(t *testing.T) {
+	store := NewMemoryStore(&UserDB{
+		Roles: []Role{{Name: "writer"}},
+		Users: []User{
+			{ID: "kept", Email: "kept@example.com", PasswordHash: "a"},
+			{ID: "removed", Email: "removed@example.com", PasswordHash: "a"},
+			{ID: "disabled", Email: "disabled@example.com", PasswordHash: "a"},
+			{ID: "password", Email: "password@example.com", PasswordHash: "a"},
+			{ID: "renamed", Email: "renamed@example.com", PasswordHash: "a"},
+		},
+	})
+	live, err := NewLive(store)
+	if err != nil {
+		t.Fatalf("Error loading store: %v", err)
+	}
+	initial := live.DB()
+	if initial.Role("writer") == nil || len(initial.Users) != 0 {
+		t.Fatalf("Expected only the roles and groups to be loaded, got %v", initial)
+	}
+
+	changed, err := live.Reload()
+	if err != nil || len(changed) != 0 {
+		t.Fatalf("Expected no changes without a write, got %v, %v", changed, err)
+	}
+
+	writes := []func() error{
+		func() error { return store.DeleteUser("removed@example.com") },
+		func() error {
+			return store.UpdateUser("disabled@example.com", func(user *User) error {
+				user.Disabled = true
+				return nil
+			})
+		},
+		func() error {
+			return store.UpdateUser("password@example.com", func(user *User) error {
+				user.PasswordHash = "b"
+				return nil
+			})
+		},
+		func() error {
+			return store.UpdateUser("renamed@example.com", func(user *User) error {
+				user.Name = "Renamed"
+				return nil
+			})
+		},
+		func() error {
+			return store.Update(func(db *UserDB) error {
+				return db.AddGroup("marketing", nil, nil)
+			})
+		},
+	}
+	for i, write := range writes {
+		if err := write(); err != nil {
+			t.Fatalf("Error in write %v: %v", i, err)
+		}
+	}
+
+	changed, err = live.Reload()
+	if err != nil {
+		t.Fatalf("Error reloading: %v", err)
+	}
+	sort.Strings(changed)
+	expected := []string{"disabled", "password", "removed"}
+	if !reflect.DeepEqual(changed, expected) {
+		t.Errorf("Expected the changed users %v, got %v", expected, changed)
+	}
+
+	if current := live.DB(); current == initial || current.Group("marketing") == nil {
+		t.Errorf("Expected the new roles and groups to be swapped in")
+	}
+	if initial.Group("marketing") != nil {
+		t.Errorf("Expected the old version to stay unchanged for requests still using it")
+	}
+
+	if changed, err := live.Reload(); err != nil || len(changed) != 0 {
+		t.Errorf("Expected no changes after a reload, got %v, %v", changed, err)
+	}
+}
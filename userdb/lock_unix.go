@@ -0,0 +1,31 @@
+//go:build !windows
+// +build !windows
+
+package userdb
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileLock is an advisory lock held with flock on a lock file next to the db
+type fileLock struct {
+	file *os.File
+}
+
+func lockFile(lockPath string) (*fileLock, error) {
+	file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &fileLock{file: file}, nil
+}
+
+func (l *fileLock) unlock() error {
+	syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	return l.file.Close()
+}
@@ -0,0 +1,89 @@
+package userdb
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// loginLog keeps the time of the last login of each user by id. With a path
+// the times are kept in a small JSON file next to the user db, so logins
+// don't rewrite the db, and without one only in memory.
+type loginLog struct {
+	path   string
+	mutex  sync.Mutex
+	logins map[string]time.Time
+}
+
+func newLoginLog(dbPath string) *loginLog {
+	if dbPath == "" {
+		return &loginLog{logins: map[string]time.Time{}}
+	}
+	return &loginLog{path: dbPath + ".logins"}
+}
+
+// RecordLogin records a login of the user with an id
+func (l *loginLog) RecordLogin(id string) error {
+	now := time.Now().UTC()
+	if l.path == "" {
+		l.mutex.Lock()
+		l.logins[id] = now
+		l.mutex.Unlock()
+		return nil
+	}
+
+	lock, err := lockFile(lockPath(l.path))
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+
+	logins, err := l.read()
+	if err != nil {
+		return err
+	}
+	logins[id] = now
+
+	data, err := json.Marshal(logins)
+	if err != nil {
+		return err
+	}
+	return writeFile(l.path, data)
+}
+
+// LastLogin returns the time of the last login of the user with an id or nil
+// if they never logged in
+func (l *loginLog) LastLogin(id string) (*time.Time, error) {
+	var logins map[string]time.Time
+	if l.path == "" {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		logins = l.logins
+	} else {
+		var err error
+		if logins, err = l.read(); err != nil {
+			return nil, err
+		}
+	}
+
+	last, ok := logins[id]
+	if !ok {
+		return nil, nil
+	}
+	return &last, nil
+}
+
+func (l *loginLog) read() (map[string]time.Time, error) {
+	logins := map[string]time.Time{}
+	data, err := ioutil.ReadFile(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return logins, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(data, &logins)
+	return logins, err
+}
@@ -0,0 +1,82 @@
+package userdb
+
+import (
+	"path"
+	"strings"
+)
+
+// Permissions maps an action ("create", "update", "delete" or "*" for every
+// action) to the path rules for that action
+type Permissions map[string]*PathRules
+
+// PathRules are glob patterns of paths that are allowed or denied.
+// Patterns use path.Match syntax per segment and "**" matches any number
+// of directories (ie. content/** or static/uploads/**)
+type PathRules struct {
+	Allow []string `yaml:"allow,omitempty" json:"allow,omitempty"`
+	Deny  []string `yaml:"deny,omitempty" json:"deny,omitempty"`
+}
+
+// Allows checks if action is permitted on pathname. Deny rules take precedence
+// over allow rules and anything not explicitly allowed is denied.
+func (p Permissions) Allows(action, pathname string) bool {
+	if p.denies(action, pathname) {
+		return false
+	}
+	for _, key := range []string{action, "*"} {
+		if rules := p[key]; rules != nil && matchAny(rules.Allow, pathname) {
+			return true
+		}
+	}
+	return false
+}
+
+// denies checks if a deny rule matches action on pathname
+func (p Permissions) denies(action, pathname string) bool {
+	for _, key := range []string{action, "*"} {
+		if rules := p[key]; rules != nil && matchAny(rules.Deny, pathname) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, pathname string) bool {
+	for _, pattern := range patterns {
+		if MatchPath(pattern, pathname) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchPath matches a path against a glob pattern where "**" matches zero or
+// more path segments
+func MatchPath(pattern, pathname string) bool {
+	return matchSegments(
+		strings.Split(strings.Trim(pattern, "/"), "/"),
+		strings.Split(strings.Trim(pathname, "/"), "/"),
+	)
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], segments[0]); err != nil || !ok {
+			return false
+		}
+		pattern = pattern[1:]
+		segments = segments[1:]
+	}
+	return len(segments) == 0
+}
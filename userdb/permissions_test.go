@@ -0,0 +1,103 @@
+package userdb
+
+import "testing"
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{pattern: "README.md", path: "README.md", expected: true},
+		{pattern: "/README.md", path: "README.md/", expected: true},
+		{pattern: "README.md", path: "docs/README.md", expected: false},
+		{pattern: "*.md", path: "README.md", expected: true},
+		{pattern: "*.md", path: "docs/README.md", expected: false},
+		{pattern: "docs/*", path: "docs/README.md", expected: true},
+		{pattern: "docs/*", path: "docs/api/README.md", expected: false},
+		{pattern: "docs/?.md", path: "docs/a.md", expected: true},
+		{pattern: "docs/[ab].md", path: "docs/c.md", expected: false},
+		{pattern: "**", path: "README.md", expected: true},
+		{pattern: "**", path: "a/b/c/d.md", expected: true},
+		{pattern: "content/**", path: "content/post.md", expected: true},
+		{pattern: "content/**", path: "content/2016/01/post.md", expected: true},
+		{pattern: "content/**", path: "content", expected: true},
+		{pattern: "content/**", path: "static/post.md", expected: false},
+		{pattern: "**/*.md", path: "README.md", expected: true},
+		{pattern: "**/*.md", path: "a/b/c.md", expected: true},
+		{pattern: "**/*.md", path: "a/b/c.html", expected: false},
+		{pattern: "content/**/index.md", path: "content/index.md", expected: true},
+		{pattern: "content/**/index.md", path: "content/a/b/index.md", expected: true},
+		{pattern: "content/**/index.md", path: "content/a/b/post.md", expected: false},
+		{pattern: "**/drafts/**", path: "content/drafts/post.md", expected: true},
+		{pattern: "**/drafts/**", path: "content/posts/post.md", expected: false},
+		{pattern: "[", path: "[", expected: false},
+	}
+
+	for _, test := range tests {
+		if matches := MatchPath(test.pattern, test.path); matches != test.expected {
+			t.Errorf("MatchPath(%q, %q): expected %v, got %v", test.pattern, test.path, test.expected, matches)
+		}
+	}
+}
+
+func TestPermissionsAllows(t *testing.T) {
+	permissions := Permissions{
+		"*": {
+			Allow: []string{"content/**"},
+			Deny:  []string{"content/locked/**"},
+		},
+		"create": {
+			Allow: []string{"static/uploads/**"},
+		},
+		"delete": {
+			Deny: []string{"content/pages/**"},
+		},
+	}
+
+	tests := []struct {
+		permissions Permissions
+		action      string
+		path        string
+		expected    bool
+	}{
+		{permissions: permissions, action: "update", path: "content/post.md", expected: true},
+		{permissions: permissions, action: "create", path: "content/2016/post.md", expected: true},
+		{permissions: permissions, action: "update", path: "content/locked/post.md", expected: false},
+		{permissions: permissions, action: "create", path: "static/uploads/img.png", expected: true},
+		{permissions: permissions, action: "update", path: "static/uploads/img.png", expected: false},
+		{permissions: permissions, action: "delete", path: "content/pages/about.md", expected: false},
+		{permissions: permissions, action: "update", path: "content/pages/about.md", expected: true},
+		{permissions: permissions, action: "update", path: "README.md", expected: false},
+		{permissions: Permissions{}, action: "update", path: "README.md", expected: false},
+		{permissions: Permissions{"update": nil}, action: "update", path: "README.md", expected: false},
+		{permissions: Permissions{"update": {Deny: []string{"**"}}, "*": {Allow: []string{"**"}}}, action: "update", path: "README.md", expected: false},
+	}
+
+	for _, test := range tests {
+		if allowed := test.permissions.Allows(test.action, test.path); allowed != test.expected {
+			t.Errorf("Allows(%q, %q): expected %v, got %v", test.action, test.path, test.expected, allowed)
+		}
+	}
+}
+
+func TestUserHasPermission(t *testing.T) {
+	db := &UserDB{}
+	tests := []struct {
+		user     *User
+		action   string
+		path     string
+		expected bool
+	}{
+		{user: &User{Email: "open@example.com"}, action: "delete", path: "README.md", expected: true},
+		{user: &User{Email: "limited@example.com", Permissions: Permissions{"*": {Allow: []string{"content/**"}}}}, action: "update", path: "content/post.md", expected: true},
+		{user: &User{Email: "limited@example.com", Permissions: Permissions{"*": {Allow: []string{"content/**"}}}}, action: "update", path: "README.md", expected: false},
+		{user: &User{Email: "none@example.com", Permissions: Permissions{}}, action: "update", path: "README.md", expected: false},
+	}
+
+	for _, test := range tests {
+		if allowed := db.HasPermission(test.user, test.action, test.path); allowed != test.expected {
+			t.Errorf("HasPermission(%v, %q, %q): expected %v, got %v", test.user.Email, test.action, test.path, test.expected, allowed)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package userdb
+
+import "fmt"
+
+// Role is a named set of permissions that users can be granted.
+// Read only roles can't write to the repository at all.
+type Role struct {
+	Name        string      `yaml:"name" json:"name"`
+	ReadOnly    bool        `yaml:"read_only,omitempty" json:"read_only,omitempty"`
+	Permissions Permissions `yaml:"permissions,omitempty" json:"permissions,omitempty"`
+}
+
+// Group is a named set of permissions and roles shared by its members
+type Group struct {
+	Name        string      `yaml:"name" json:"name"`
+	Roles       []string    `yaml:"roles,omitempty" json:"roles,omitempty"`
+	Permissions Permissions `yaml:"permissions,omitempty" json:"permissions,omitempty"`
+}
+
+// DefaultRoles are available without being defined in the db. Defining a role
+// with the same name in the db overrides the default.
+var DefaultRoles = []Role{
+	{Name: "admin", Permissions: Permissions{"*": {Allow: []string{"**"}}}},
+	{Name: "editor", Permissions: Permissions{"*": {Allow: []string{"**"}}}},
+	{Name: "contributor", Permissions: Permissions{
+		"create": {Allow: []string{"**"}},
+		"update": {Allow: []string{"**"}},
+	}},
+	{Name: "viewer", ReadOnly: true, Permissions: Permissions{}},
+}
+
+// Role looks up a role by name, falling back to the default roles
+func (db *UserDB) Role(name string) *Role {
+	for i := range db.Roles {
+		if db.Roles[i].Name == name {
+			return &db.Roles[i]
+		}
+	}
+	for i := range DefaultRoles {
+		if DefaultRoles[i].Name == name {
+			role := DefaultRoles[i]
+			return &role
+		}
+	}
+	return nil
+}
+
+// AllRoles returns the roles defined in the db and the default roles that
+// haven't been overridden
+func (db *UserDB) AllRoles() []Role {
+	roles := append([]Role{}, db.Roles...)
+	for _, role := range DefaultRoles {
+		defined := false
+		for _, r := range db.Roles {
+			if r.Name == role.Name {
+				defined = true
+			}
+		}
+		if !defined {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// Group looks up a group by name
+func (db *UserDB) Group(name string) *Group {
+	for i := range db.Groups {
+		if db.Groups[i].Name == name {
+			return &db.Groups[i]
+		}
+	}
+	return nil
+}
+
+// AddRole defines a role, replacing any existing role with the same name
+func (db *UserDB) AddRole(name string, permissions Permissions) {
+	for i := range db.Roles {
+		if db.Roles[i].Name == name {
+			db.Roles[i].Permissions = permissions
+			return
+		}
+	}
+	db.Roles = append(db.Roles, Role{Name: name, Permissions: permissions})
+}
+
+// AddGroup defines a group with the roles of its members, replacing any
+// existing group with the same name
+func (db *UserDB) AddGroup(name string, permissions Permissions, roles []string) error {
+	for _, role := range roles {
+		if db.Role(role) == nil {
+			return fmt.Errorf("No role %v", role)
+		}
+	}
+
+	for i := range db.Groups {
+		if db.Groups[i].Name == name {
+			db.Groups[i].Permissions = permissions
+			db.Groups[i].Roles = roles
+			return nil
+		}
+	}
+	db.Groups = append(db.Groups, Group{Name: name, Roles: roles, Permissions: permissions})
+	return nil
+}
+
+// DeleteGroup removes a group from the db and all its members from it
+func (db *UserDB) DeleteGroup(name string) error {
+	groups := []Group{}
+	for _, group := range db.Groups {
+		if group.Name != name {
+			groups = append(groups, group)
+		}
+	}
+	if len(groups) == len(db.Groups) {
+		return fmt.Errorf("No group %v", name)
+	}
+	db.Groups = groups
+
+	for i := range db.Users {
+		db.Users[i].Groups = without(db.Users[i].Groups, name)
+	}
+	return nil
+}
+
+// DeleteRole removes a role from the db and revokes it from all users.
+// Default roles can't be deleted, only the overrides in the db.
+func (db *UserDB) DeleteRole(name string) error {
+	roles := []Role{}
+	for _, role := range db.Roles {
+		if role.Name != name {
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) == len(db.Roles) {
+		return fmt.Errorf("No role %v defined in the db", name)
+	}
+	db.Roles = roles
+
+	if db.Role(name) == nil {
+		for i := range db.Users {
+			db.Users[i].Roles = without(db.Users[i].Roles, name)
+		}
+		for i := range db.Groups {
+			db.Groups[i].Roles = without(db.Groups[i].Roles, name)
+		}
+	}
+	return nil
+}
+
+// Grant assigns a role (or a group if group is set) to a user
+func (db *UserDB) Grant(user *User, name string, group bool) error {
+	if group {
+		if db.Group(name) == nil {
+			return fmt.Errorf("No group %v", name)
+		}
+		if !contains(user.Groups, name) {
+			user.Groups = append(user.Groups, name)
+		}
+		return nil
+	}
+
+	if db.Role(name) == nil {
+		return fmt.Errorf("No role %v", name)
+	}
+	if !contains(user.Roles, name) {
+		user.Roles = append(user.Roles, name)
+	}
+	return nil
+}
+
+// Revoke removes a role (or a group if group is set) from a user
+func (db *UserDB) Revoke(user *User, name string, group bool) error {
+	if group {
+		if !contains(user.Groups, name) {
+			return fmt.Errorf("User %v is not in group %v", user.Email, name)
+		}
+		user.Groups = without(user.Groups, name)
+		return nil
+	}
+
+	if !contains(user.Roles, name) {
+		return fmt.Errorf("User %v doesn't have role %v", user.Email, name)
+	}
+	user.Roles = without(user.Roles, name)
+	return nil
+}
+
+// HasRole checks if a user has a role, either granted directly or through
+// one of their groups. Group names are not roles.
+func (db *UserDB) HasRole(user *User, name string) bool {
+	return contains(db.userRoles(user), name)
+}
+
+// userRoles returns the roles granted to a user followed by the roles of
+// their groups
+func (db *UserDB) userRoles(user *User) []string {
+	roles := append([]string{}, user.Roles...)
+	for _, name := range user.Groups {
+		if group := db.Group(name); group != nil {
+			roles = append(roles, group.Roles...)
+		}
+	}
+	return roles
+}
+
+// permissionSets returns the permissions of a user followed by the
+// permissions of each of their roles and groups
+func (db *UserDB) permissionSets(user *User) []Permissions {
+	sets := []Permissions{}
+	if user.Permissions != nil {
+		sets = append(sets, user.Permissions)
+	}
+	for _, name := range db.userRoles(user) {
+		if role := db.Role(name); role != nil {
+			sets = append(sets, role.Permissions)
+		}
+	}
+	for _, name := range user.Groups {
+		if group := db.Group(name); group != nil {
+			sets = append(sets, group.Permissions)
+		}
+	}
+	return sets
+}
+
+// IsReadOnly checks if a user only has read access, which is the case when
+// all their roles are read only and they have no groups or rules of their own
+func (db *UserDB) IsReadOnly(user *User) bool {
+	if len(user.Roles) == 0 || len(user.Groups) > 0 || user.Permissions != nil {
+		return false
+	}
+	for _, name := range user.Roles {
+		if role := db.Role(name); role != nil && !role.ReadOnly {
+			return false
+		}
+	}
+	return true
+}
+
+// HasPermission checks if a user may perform an action ("create", "update"
+// or "delete") on a path through their own rules, roles or groups. Users
+// without any rules, roles or groups are unrestricted.
+//
+// Each role and group is evaluated on its own and the action is allowed if
+// any of them grants it, so a deny rule in one role doesn't take away what
+// another role or group allows. Deny rules in the user's own permissions
+// apply on top of all their roles and groups.
+func (db *UserDB) HasPermission(user *User, action, pathname string) bool {
+	if user.Permissions == nil && len(user.Roles) == 0 && len(user.Groups) == 0 {
+		return true
+	}
+	if user.Permissions.denies(action, pathname) {
+		return false
+	}
+	for _, permissions := range db.permissionSets(user) {
+		if permissions.Allows(action, pathname) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func without(list []string, value string) []string {
+	result := []string{}
+	for _, v := range list {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,243 @@
+package userdb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testRolesDB() *UserDB {
+	return &UserDB{
+		Roles: []Role{
+			{Name: "writer", Permissions: Permissions{"*": {Allow: []string{"content/**"}, Deny: []string{"content/locked/**"}}}},
+			{Name: "locksmith", Permissions: Permissions{"*": {Allow: []string{"content/locked/**"}}}},
+			{Name: "editor", Permissions: Permissions{"update": {Allow: []string{"**"}}}},
+		},
+		Groups: []Group{
+			{Name: "marketing", Permissions: Permissions{"*": {Allow: []string{"static/campaigns/**"}, Deny: []string{"content/**"}}}},
+			{Name: "locksmiths", Roles: []string{"locksmith"}},
+			{Name: "admin"},
+		},
+	}
+}
+
+func TestHasPermissionWithRolesAndGroups(t *testing.T) {
+	db := testRolesDB()
+	tests := []struct {
+		name     string
+		user     *User
+		action   string
+		path     string
+		expected bool
+	}{
+		{name: "role allows", user: &User{Roles: []string{"writer"}}, action: "create", path: "content/post.md", expected: true},
+		{name: "role denies", user: &User{Roles: []string{"writer"}}, action: "create", path: "content/locked/post.md", expected: false},
+		{name: "other role allows what one denies", user: &User{Roles: []string{"writer", "locksmith"}}, action: "update", path: "content/locked/post.md", expected: true},
+		{name: "group deny doesn't remove role allow", user: &User{Roles: []string{"writer"}, Groups: []string{"marketing"}}, action: "update", path: "content/post.md", expected: true},
+		{name: "group allows", user: &User{Roles: []string{"writer"}, Groups: []string{"marketing"}}, action: "create", path: "static/campaigns/a.png", expected: true},
+		{name: "nothing allows", user: &User{Roles: []string{"writer"}, Groups: []string{"marketing"}}, action: "create", path: "config.yml", expected: false},
+		{name: "action specific role", user: &User{Roles: []string{"editor"}}, action: "delete", path: "README.md", expected: false},
+		{name: "user deny wins over roles", user: &User{Roles: []string{"editor"}, Permissions: Permissions{"*": {Deny: []string{"config.yml"}}}}, action: "update", path: "config.yml", expected: false},
+		{name: "user allow adds to roles", user: &User{Roles: []string{"writer"}, Permissions: Permissions{"create": {Allow: []string{"static/**"}}}}, action: "create", path: "static/a.png", expected: true},
+		{name: "default role", user: &User{Roles: []string{"contributor"}}, action: "update", path: "README.md", expected: true},
+		{name: "default role denies", user: &User{Roles: []string{"contributor"}}, action: "delete", path: "README.md", expected: false},
+		{name: "read only role", user: &User{Roles: []string{"viewer"}}, action: "update", path: "README.md", expected: false},
+		{name: "unknown role", user: &User{Roles: []string{"missing"}}, action: "update", path: "README.md", expected: false},
+		{name: "role of a group", user: &User{Groups: []string{"locksmiths"}}, action: "update", path: "content/locked/post.md", expected: true},
+		{name: "unrestricted", user: &User{}, action: "delete", path: "README.md", expected: true},
+	}
+
+	for _, test := range tests {
+		if allowed := db.HasPermission(test.user, test.action, test.path); allowed != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.name, test.expected, allowed)
+		}
+	}
+}
+
+func TestRoleLookup(t *testing.T) {
+	db := testRolesDB()
+	db.AddRole("viewer", Permissions{"update": {Allow: []string{"docs/**"}}})
+
+	tests := []struct {
+		name     string
+		exists   bool
+		readOnly bool
+	}{
+		{name: "writer", exists: true},
+		{name: "admin", exists: true},
+		{name: "viewer", exists: true},
+		{name: "missing", exists: false},
+	}
+
+	for _, test := range tests {
+		role := db.Role(test.name)
+		if (role != nil) != test.exists {
+			t.Errorf("Role(%q): expected exists=%v", test.name, test.exists)
+			continue
+		}
+		if role != nil && role.ReadOnly != test.readOnly {
+			t.Errorf("Role(%q): expected read only %v", test.name, test.readOnly)
+		}
+	}
+
+	names := []string{}
+	for _, role := range db.AllRoles() {
+		names = append(names, role.Name)
+	}
+	expected := []string{"writer", "locksmith", "editor", "viewer", "admin", "contributor"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("AllRoles: expected %v, got %v", expected, names)
+	}
+}
+
+func TestDeleteRole(t *testing.T) {
+	db := testRolesDB()
+	db.AddRole("admin", Permissions{})
+	db.Users = []User{{Email: "a@example.com", Roles: []string{"writer", "admin", "editor"}}}
+
+	tests := []struct {
+		name  string
+		err   bool
+		roles []string
+	}{
+		{name: "writer", roles: []string{"admin", "editor"}},
+		{name: "writer", err: true, roles: []string{"admin", "editor"}},
+		// Deleting an override keeps the default role
+		{name: "admin", roles: []string{"admin", "editor"}},
+		{name: "admin", err: true, roles: []string{"admin", "editor"}},
+		{name: "editor", roles: []string{"admin", "editor"}},
+	}
+
+	for _, test := range tests {
+		err := db.DeleteRole(test.name)
+		if test.err != (err != nil) {
+			t.Errorf("DeleteRole(%q): expected error %v, got %v", test.name, test.err, err)
+		}
+		if !reflect.DeepEqual(db.Users[0].Roles, test.roles) {
+			t.Errorf("DeleteRole(%q): expected user roles %v, got %v", test.name, test.roles, db.Users[0].Roles)
+		}
+	}
+
+	if err := db.DeleteRole("locksmith"); err != nil {
+		t.Fatalf("Unexpected error deleting role: %v", err)
+	}
+	if roles := db.Group("locksmiths").Roles; len(roles) != 0 {
+		t.Errorf("Expected the role to be removed from groups, got %v", roles)
+	}
+}
+
+func TestAddAndDeleteGroup(t *testing.T) {
+	db := testRolesDB()
+	db.Users = []User{{Email: "a@example.com", Groups: []string{"marketing", "sales"}}}
+
+	groups := len(db.Groups)
+	db.AddGroup("sales", Permissions{"*": {Allow: []string{"sales/**"}}}, nil)
+	db.AddGroup("marketing", Permissions{"*": {Allow: []string{"marketing/**"}}}, []string{"writer"})
+	if len(db.Groups) != groups+1 {
+		t.Fatalf("Expected %v groups, got %v", groups+1, len(db.Groups))
+	}
+	if group := db.Group("marketing"); group == nil || !group.Permissions.Allows("update", "marketing/a.md") || !reflect.DeepEqual(group.Roles, []string{"writer"}) {
+		t.Errorf("Expected AddGroup to replace the marketing group, got %+v", group)
+	}
+	if err := db.AddGroup("marketing", nil, []string{"missing"}); err == nil {
+		t.Errorf("Expected an error adding a group with an unknown role")
+	}
+
+	if err := db.DeleteGroup("marketing"); err != nil {
+		t.Fatalf("Unexpected error deleting group: %v", err)
+	}
+	if db.Group("marketing") != nil {
+		t.Errorf("Expected the marketing group to be gone")
+	}
+	if !reflect.DeepEqual(db.Users[0].Groups, []string{"sales"}) {
+		t.Errorf("Expected the user to only be in sales, got %v", db.Users[0].Groups)
+	}
+	if err := db.DeleteGroup("marketing"); err == nil {
+		t.Errorf("Expected an error deleting a missing group")
+	}
+}
+
+func TestGrantAndRevoke(t *testing.T) {
+	db := testRolesDB()
+	user := &User{Email: "a@example.com"}
+
+	tests := []struct {
+		grant  bool
+		name   string
+		group  bool
+		err    bool
+		roles  []string
+		groups []string
+	}{
+		{grant: true, name: "writer", roles: []string{"writer"}},
+		{grant: true, name: "writer", roles: []string{"writer"}},
+		{grant: true, name: "admin", roles: []string{"writer", "admin"}},
+		{grant: true, name: "missing", err: true, roles: []string{"writer", "admin"}},
+		{grant: true, name: "marketing", group: true, roles: []string{"writer", "admin"}, groups: []string{"marketing"}},
+		{grant: true, name: "writer", group: true, err: true, roles: []string{"writer", "admin"}, groups: []string{"marketing"}},
+		{grant: false, name: "writer", roles: []string{"admin"}, groups: []string{"marketing"}},
+		{grant: false, name: "writer", err: true, roles: []string{"admin"}, groups: []string{"marketing"}},
+		{grant: false, name: "marketing", group: true, roles: []string{"admin"}, groups: []string{}},
+	}
+
+	for i, test := range tests {
+		var err error
+		if test.grant {
+			err = db.Grant(user, test.name, test.group)
+		} else {
+			err = db.Revoke(user, test.name, test.group)
+		}
+		if test.err != (err != nil) {
+			t.Errorf("Test %v: expected error %v, got %v", i, test.err, err)
+		}
+		if !reflect.DeepEqual(user.Roles, test.roles) || (len(test.groups) > 0 || len(user.Groups) > 0) && !reflect.DeepEqual(user.Groups, test.groups) {
+			t.Errorf("Test %v: expected roles %v and groups %v, got %v and %v", i, test.roles, test.groups, user.Roles, user.Groups)
+		}
+	}
+}
+
+func TestIsReadOnly(t *testing.T) {
+	db := testRolesDB()
+	db.Roles = append(db.Roles, Role{Name: "auditor", ReadOnly: true})
+
+	tests := []struct {
+		name     string
+		user     *User
+		expected bool
+	}{
+		{name: "no roles", user: &User{}, expected: false},
+		{name: "viewer", user: &User{Roles: []string{"viewer"}}, expected: true},
+		{name: "read only roles", user: &User{Roles: []string{"viewer", "auditor"}}, expected: true},
+		{name: "mixed roles", user: &User{Roles: []string{"viewer", "writer"}}, expected: false},
+		{name: "viewer in a group", user: &User{Roles: []string{"viewer"}, Groups: []string{"marketing"}}, expected: false},
+		{name: "viewer with rules", user: &User{Roles: []string{"viewer"}, Permissions: Permissions{}}, expected: false},
+		{name: "unknown role", user: &User{Roles: []string{"missing"}}, expected: true},
+	}
+
+	for _, test := range tests {
+		if readOnly := db.IsReadOnly(test.user); readOnly != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.name, test.expected, readOnly)
+		}
+	}
+}
+
+func TestHasRole(t *testing.T) {
+	db := testRolesDB()
+	user := &User{Roles: []string{"writer"}, Groups: []string{"marketing", "locksmiths", "admin", "missing"}}
+
+	tests := []struct {
+		role     string
+		expected bool
+	}{
+		{role: "writer", expected: true},
+		{role: "locksmith", expected: true},
+		{role: "marketing", expected: false},
+		{role: "admin", expected: false},
+		{role: "missing", expected: false},
+	}
+
+	for _, test := range tests {
+		if hasRole := db.HasRole(user, test.role); hasRole != test.expected {
+			t.Errorf("HasRole(%q): expected %v, got %v", test.role, test.expected, hasRole)
+		}
+	}
+}
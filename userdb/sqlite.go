@@ -0,0 +1,379 @@
+package userdb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	// Pure Go SQLite driver, registers itself as "sqlite"
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS meta (key TEXT PRIMARY KEY, value TEXT NOT NULL);
+CREATE TABLE IF NOT EXISTS users (id TEXT PRIMARY KEY, email TEXT NOT NULL UNIQUE, data TEXT NOT NULL);
+CREATE TABLE IF NOT EXISTS api_keys (hash TEXT PRIMARY KEY, user_id TEXT NOT NULL);
+CREATE INDEX IF NOT EXISTS api_keys_user_id ON api_keys (user_id);
+CREATE TABLE IF NOT EXISTS roles (name TEXT PRIMARY KEY, data TEXT NOT NULL);
+CREATE TABLE IF NOT EXISTS user_groups (name TEXT PRIMARY KEY, data TEXT NOT NULL);
+INSERT OR IGNORE INTO meta (key, value) VALUES ('version', '0');
+`
+
+// SQLiteStore keeps the db in an embedded SQLite database. Users, roles and
+// groups are rows holding their JSON encoding. Users are looked up by the
+// indexes on their id and email and the api_keys table, which maps the hash
+// of every key to its user. Logins are kept in a file next to the database
+// like for the other stores.
+type SQLiteStore struct {
+	*loginLog
+
+	mutex  sync.Mutex
+	path   string
+	reader *sql.DB
+	writer *sql.DB
+}
+
+// NewSQLiteStore creates a store for an SQLite database file
+func NewSQLiteStore(path string) *SQLiteStore {
+	return &SQLiteStore{loginLog: newLoginLog(path), path: path}
+}
+
+// Version is a counter incremented by every write
+func (s *SQLiteStore) Version() ([]byte, error) {
+	reader, _, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+
+	var version string
+	err = reader.QueryRow(`SELECT value FROM meta WHERE key = 'version'`).Scan(&version)
+	return []byte(version), err
+}
+
+// Load reads all users, roles and groups
+func (s *SQLiteStore) Load() (*UserDB, error) {
+	db := &UserDB{}
+	err := s.transaction(false, func(tx *sql.Tx) error {
+		return loadDB(tx, db, true)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// LoadRoles reads the roles and groups
+func (s *SQLiteStore) LoadRoles() (*UserDB, error) {
+	db := &UserDB{}
+	err := s.transaction(false, func(tx *sql.Tx) error {
+		return loadDB(tx, db, false)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Update replaces all users, roles and groups with the result of fn
+func (s *SQLiteStore) Update(fn func(db *UserDB) error) error {
+	return s.transaction(true, func(tx *sql.Tx) error {
+		db := &UserDB{}
+		if err := loadDB(tx, db, true); err != nil {
+			return err
+		}
+		if err := fn(db); err != nil {
+			return err
+		}
+
+		for _, table := range []string{"users", "api_keys", "roles", "user_groups"} {
+			if _, err := tx.Exec(`DELETE FROM ` + table); err != nil {
+				return err
+			}
+		}
+		for i := range db.Users {
+			if err := insertUser(tx, &db.Users[i]); err != nil {
+				return err
+			}
+		}
+		for _, role := range db.Roles {
+			data, err := json.Marshal(&role)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`INSERT INTO roles (name, data) VALUES (?, ?)`, role.Name, string(data)); err != nil {
+				return err
+			}
+		}
+		for _, group := range db.Groups {
+			data, err := json.Marshal(&group)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`INSERT INTO user_groups (name, data) VALUES (?, ?)`, group.Name, string(data)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Get looks up a user by id
+func (s *SQLiteStore) Get(id string) (*User, error) {
+	return s.queryUser(`SELECT data FROM users WHERE id = ?`, id)
+}
+
+// LookupByEmail looks up a user by email
+func (s *SQLiteStore) LookupByEmail(email string) (*User, error) {
+	return s.queryUser(`SELECT data FROM users WHERE email = ?`, email)
+}
+
+// LookupByKeyHash looks up the user of an API key by the hash of the key
+func (s *SQLiteStore) LookupByKeyHash(hash string) (*User, error) {
+	return s.queryUser(`SELECT users.data FROM api_keys JOIN users ON users.id = api_keys.user_id WHERE api_keys.hash = ?`, hash)
+}
+
+// AddUser inserts a user
+func (s *SQLiteStore) AddUser(user *User) error {
+	return s.transaction(true, func(tx *sql.Tx) error {
+		existing, err := scanUser(tx.QueryRow(`SELECT data FROM users WHERE email = ?`, user.Email))
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return fmt.Errorf("A user with the email %v already exists", user.Email)
+		}
+		return insertUser(tx, user)
+	})
+}
+
+// UpdateUser changes a single user
+func (s *SQLiteStore) UpdateUser(email string, fn func(user *User) error) error {
+	return s.transaction(true, func(tx *sql.Tx) error {
+		user, err := scanUser(tx.QueryRow(`SELECT data FROM users WHERE email = ?`, email))
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return fmt.Errorf("No user with email %v", email)
+		}
+		id := user.ID
+		if err := fn(user); err != nil {
+			return err
+		}
+
+		var other string
+		err = tx.QueryRow(`SELECT id FROM users WHERE email = ? AND id != ?`, user.Email, id).Scan(&other)
+		if err == nil {
+			return fmt.Errorf("A user with the email %v already exists", user.Email)
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+
+		user.ID = id
+		data, err := json.Marshal(user)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE users SET email = ?, data = ? WHERE id = ?`, user.Email, string(data), id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM api_keys WHERE user_id = ?`, id); err != nil {
+			return err
+		}
+		return insertKeys(tx, user)
+	})
+}
+
+// DeleteUser removes a user and their keys
+func (s *SQLiteStore) DeleteUser(email string) error {
+	return s.transaction(true, func(tx *sql.Tx) error {
+		user, err := scanUser(tx.QueryRow(`SELECT data FROM users WHERE email = ?`, email))
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return fmt.Errorf("No user with email %v", email)
+		}
+		return deleteUser(tx, user.ID)
+	})
+}
+
+func (s *SQLiteStore) queryUser(query string, arg string) (*User, error) {
+	reader, _, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	return scanUser(reader.QueryRow(query, arg))
+}
+
+// transaction runs fn in a transaction and increments the version for
+// writes. Write transactions take the lock of the database right away (see
+// open), so concurrent writers wait for each other instead of failing, while
+// reads use plain transactions that don't block anybody.
+func (s *SQLiteStore) transaction(write bool, fn func(tx *sql.Tx) error) error {
+	conn, writer, err := s.open()
+	if err != nil {
+		return err
+	}
+	if write {
+		conn = writer
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if write {
+		var version string
+		if err := tx.QueryRow(`SELECT value FROM meta WHERE key = 'version'`).Scan(&version); err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(version)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE meta SET value = ? WHERE key = 'version'`, strconv.Itoa(n+1)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// open connects to the database, creating it with 0600 permissions and the
+// schema if it doesn't exist yet. It returns one pool for reads and one for
+// writes, whose transactions start with BEGIN IMMEDIATE.
+func (s *SQLiteStore) open() (*sql.DB, *sql.DB, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.reader != nil {
+		return s.reader, s.writer, nil
+	}
+
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	file.Close()
+
+	reader, err := sql.Open("sqlite", s.path+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, nil, err
+	}
+	writer, err := sql.Open("sqlite", s.path+"?_pragma=busy_timeout(5000)&_txlock=immediate")
+	if err != nil {
+		reader.Close()
+		return nil, nil, err
+	}
+	if _, err := writer.Exec(sqliteSchema); err != nil {
+		reader.Close()
+		writer.Close()
+		return nil, nil, err
+	}
+
+	s.reader = reader
+	s.writer = writer
+	return reader, writer, nil
+}
+
+// loadDB reads the roles and groups and, if users is set, all users
+func loadDB(tx *sql.Tx, db *UserDB, users bool) error {
+	if users {
+		err := loadRows(tx, `SELECT data FROM users ORDER BY rowid`, func(data []byte) error {
+			db.Users = append(db.Users, User{})
+			return json.Unmarshal(data, &db.Users[len(db.Users)-1])
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err := loadRows(tx, `SELECT data FROM roles ORDER BY rowid`, func(data []byte) error {
+		db.Roles = append(db.Roles, Role{})
+		return json.Unmarshal(data, &db.Roles[len(db.Roles)-1])
+	})
+	if err != nil {
+		return err
+	}
+
+	return loadRows(tx, `SELECT data FROM user_groups ORDER BY rowid`, func(data []byte) error {
+		db.Groups = append(db.Groups, Group{})
+		return json.Unmarshal(data, &db.Groups[len(db.Groups)-1])
+	})
+}
+
+func loadRows(tx *sql.Tx, query string, fn func([]byte) error) error {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// scanUser decodes the user of a query for its data, nil if there is none
+func scanUser(row *sql.Row) (*User, error) {
+	var data []byte
+	if err := row.Scan(&data); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	user := &User{}
+	if err := json.Unmarshal(data, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// insertUser adds the row of a user and the rows of their keys
+func insertUser(tx *sql.Tx, user *User) error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO users (id, email, data) VALUES (?, ?, ?)`, user.ID, user.Email, string(data)); err != nil {
+		return err
+	}
+	return insertKeys(tx, user)
+}
+
+// insertKeys adds the rows mapping the keys of a user to the user
+func insertKeys(tx *sql.Tx, user *User) error {
+	for _, key := range user.Keys {
+		if _, err := tx.Exec(`INSERT INTO api_keys (hash, user_id) VALUES (?, ?)`, key.Hash, user.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteUser removes the row of a user and the rows of their keys
+func deleteUser(tx *sql.Tx, id string) error {
+	if _, err := tx.Exec(`DELETE FROM api_keys WHERE user_id = ?`, id); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM users WHERE id = ?`, id)
+	return err
+}
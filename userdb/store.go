@@ -0,0 +1,349 @@
+package userdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Store keeps the users, roles and groups. Lookups and writes of single
+// users go straight to the store, so a store backed by a database doesn't
+// have to load every user for them.
+type Store interface {
+	// Version identifies the stored state and changes with every write
+	Version() ([]byte, error)
+	// Load reads all roles, groups and users
+	Load() (*UserDB, error)
+	// LoadRoles reads the roles and groups without the users
+	LoadRoles() (*UserDB, error)
+	// Update loads all roles, groups and users, applies fn and saves the
+	// result unless fn fails
+	Update(fn func(db *UserDB) error) error
+
+	// Get returns the user with an id or nil
+	Get(id string) (*User, error)
+	// LookupByEmail returns the user with an email or nil
+	LookupByEmail(email string) (*User, error)
+	// LookupByKeyHash returns the user with an API key by its hash or nil
+	LookupByKeyHash(hash string) (*User, error)
+
+	// AddUser adds a user. Fails if a user with the email already exists.
+	AddUser(user *User) error
+	// UpdateUser applies fn to the user with an email and saves it unless fn
+	// fails. Fails if there is no such user or its new email is taken.
+	UpdateUser(email string, fn func(user *User) error) error
+	// DeleteUser removes the user with an email
+	DeleteUser(email string) error
+
+	// RecordLogin records a login of the user with an id
+	RecordLogin(id string) error
+	// LastLogin returns the time of the last login of the user with an id or
+	// nil if they never logged in
+	LastLogin(id string) (*time.Time, error)
+}
+
+// NewStore picks a store by the extension of the path: .json for a JSON
+// file, .db, .sqlite or .sqlite3 for an SQLite database and a YAML file for
+// anything else
+func NewStore(path string) Store {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return NewJSONStore(path)
+	case ".db", ".sqlite", ".sqlite3":
+		return NewSQLiteStore(path)
+	default:
+		return NewYAMLStore(path)
+	}
+}
+
+// fileStore keeps the db in memory, indexed for lookups. With a path the db
+// is read from a file, which is read again when it's replaced, and every
+// write replaces the file while holding a lock on it.
+type fileStore struct {
+	*loginLog
+
+	path      string
+	marshal   func(interface{}) ([]byte, error)
+	unmarshal func([]byte, interface{}) error
+
+	writeMutex sync.Mutex
+	mutex      sync.Mutex
+	cached     *indexedDB
+	info       os.FileInfo
+}
+
+// NewYAMLStore creates a store for a YAML file
+func NewYAMLStore(path string) Store {
+	return &fileStore{loginLog: newLoginLog(path), path: path, marshal: yaml.Marshal, unmarshal: yaml.Unmarshal}
+}
+
+// NewJSONStore creates a store for a JSON file
+func NewJSONStore(path string) Store {
+	return &fileStore{loginLog: newLoginLog(path), path: path, marshal: marshalJSON, unmarshal: unmarshalJSON}
+}
+
+// NewMemoryStore creates a store that only keeps db in memory
+func NewMemoryStore(db *UserDB) Store {
+	return &fileStore{loginLog: newLoginLog(""), marshal: marshalJSON, unmarshal: unmarshalJSON, cached: newIndexedDB(db)}
+}
+
+func (s *fileStore) Version() ([]byte, error) {
+	data, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return checksum(data), nil
+}
+
+func (s *fileStore) Load() (*UserDB, error) {
+	data, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	db := &UserDB{}
+	if err := s.unmarshal(data, db); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func (s *fileStore) LoadRoles() (*UserDB, error) {
+	db, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	db.Users = nil
+	return db, nil
+}
+
+func (s *fileStore) Update(fn func(db *UserDB) error) error {
+	if s.path != "" {
+		lock, err := lockFile(lockPath(s.path))
+		if err != nil {
+			return err
+		}
+		defer lock.unlock()
+	}
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	db, err := s.Load()
+	if err != nil {
+		return err
+	}
+	if err := fn(db); err != nil {
+		return err
+	}
+
+	var info os.FileInfo
+	if s.path != "" {
+		data, err := s.marshal(db)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(s.path, data); err != nil {
+			return err
+		}
+		if info, err = os.Stat(s.path); err != nil {
+			return err
+		}
+	}
+
+	s.mutex.Lock()
+	s.cached = newIndexedDB(db)
+	s.info = info
+	s.mutex.Unlock()
+	return nil
+}
+
+func (s *fileStore) Get(id string) (*User, error) {
+	db, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return copyUser(db.byID[id]), nil
+}
+
+func (s *fileStore) LookupByEmail(email string) (*User, error) {
+	db, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return copyUser(db.byEmail[email]), nil
+}
+
+func (s *fileStore) LookupByKeyHash(hash string) (*User, error) {
+	db, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return copyUser(db.byKeyHash[hash]), nil
+}
+
+func (s *fileStore) AddUser(user *User) error {
+	return s.Update(func(db *UserDB) error {
+		if db.LookupByEmail(user.Email) != nil {
+			return fmt.Errorf("A user with the email %v already exists", user.Email)
+		}
+		db.Users = append(db.Users, *user)
+		return nil
+	})
+}
+
+func (s *fileStore) UpdateUser(email string, fn func(user *User) error) error {
+	return s.Update(func(db *UserDB) error {
+		user := db.LookupByEmail(email)
+		if user == nil {
+			return fmt.Errorf("No user with email %v", email)
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+		if db.emailTaken(user) {
+			return fmt.Errorf("A user with the email %v already exists", user.Email)
+		}
+		return nil
+	})
+}
+
+func (s *fileStore) DeleteUser(email string) error {
+	return s.Update(func(db *UserDB) error {
+		if db.LookupByEmail(email) == nil {
+			return fmt.Errorf("No user with email %v", email)
+		}
+		db.Delete(email)
+		return nil
+	})
+}
+
+// current returns the cached db, reading the file again if it was replaced
+// since it was cached
+func (s *fileStore) current() (*indexedDB, error) {
+	var info os.FileInfo
+	if s.path != "" {
+		var err error
+		info, err = os.Stat(s.path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.cached != nil && sameFileInfo(s.info, info) {
+		return s.cached, nil
+	}
+	db, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	s.cached = newIndexedDB(db)
+	s.info = info
+	return s.cached, nil
+}
+
+// read returns the contents of the file, a missing file is an empty db.
+// Without a path it's the encoding of the db in memory.
+func (s *fileStore) read() ([]byte, error) {
+	if s.path == "" {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		return s.marshal(s.cached.db)
+	}
+
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return data, nil
+}
+
+// indexedDB is a db with the indexes for the lookups of the in-memory store
+type indexedDB struct {
+	db        *UserDB
+	byID      map[string]*User
+	byEmail   map[string]*User
+	byKeyHash map[string]*User
+}
+
+func newIndexedDB(db *UserDB) *indexedDB {
+	indexed := &indexedDB{
+		db:        db,
+		byID:      map[string]*User{},
+		byEmail:   map[string]*User{},
+		byKeyHash: map[string]*User{},
+	}
+	for i := range db.Users {
+		user := &db.Users[i]
+		indexed.byID[user.ID] = user
+		indexed.byEmail[user.Email] = user
+		for _, key := range user.Keys {
+			indexed.byKeyHash[key.Hash] = user
+		}
+	}
+	return indexed
+}
+
+// copyUser returns a copy of a user so callers can't change the cached db
+func copyUser(user *User) *User {
+	if user == nil {
+		return nil
+	}
+	copied := *user
+	return &copied
+}
+
+func sameFileInfo(a, b os.FileInfo) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return os.SameFile(a, b) && a.ModTime().Equal(b.ModTime()) && a.Size() == b.Size()
+}
+
+func marshalJSON(obj interface{}) ([]byte, error) {
+	return json.MarshalIndent(obj, "", "  ")
+}
+
+func unmarshalJSON(data []byte, obj interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, obj)
+}
+
+// writeFile writes data to a temporary file with 0600 permissions next to
+// filename and renames it into place
+func writeFile(filename string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename))
+	if err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
@@ -0,0 +1,240 @@
+package userdb
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testStores returns a store of every kind, all empty
+func testStores(t *testing.T) (map[string]Store, func()) {
+	dir, cleanup := tempDBPath(t, "")
+	return map[string]Store{
+		"memory": NewMemoryStore(&UserDB{}),
+		"yaml":   NewStore(filepath.Join(dir, "users.yml")),
+		"json":   NewStore(filepath.Join(dir, "users.json")),
+		"sqlite": NewStore(filepath.Join(dir, "users.db")),
+	}, cleanup
+}
+
+func TestStoreUsers(t *testing.T) {
+	stores, cleanup := testStores(t)
+	defer cleanup()
+
+	for name, store := range stores {
+		alice := &User{ID: "alice", Email: "alice@example.com", Name: "Alice"}
+		if _, _, err := alice.CreateKey("importer", KeyScope{}, 0); err != nil {
+			t.Fatalf("%v: error creating key: %v", name, err)
+		}
+		bob := &User{ID: "bob", Email: "bob@example.com", Name: "Bob"}
+		for _, user := range []*User{alice, bob} {
+			if err := store.AddUser(user); err != nil {
+				t.Fatalf("%v: error adding %v: %v", name, user.ID, err)
+			}
+		}
+
+		tests := []struct {
+			name   string
+			lookup func() (*User, error)
+			id     string
+		}{
+			{name: "by id", lookup: func() (*User, error) { return store.Get("alice") }, id: "alice"},
+			{name: "by unknown id", lookup: func() (*User, error) { return store.Get("nobody") }},
+			{name: "by email", lookup: func() (*User, error) { return store.LookupByEmail("bob@example.com") }, id: "bob"},
+			{name: "by unknown email", lookup: func() (*User, error) { return store.LookupByEmail("nobody@example.com") }},
+			{name: "by key hash", lookup: func() (*User, error) { return store.LookupByKeyHash(alice.Keys[0].Hash) }, id: "alice"},
+			{name: "by unknown key hash", lookup: func() (*User, error) { return store.LookupByKeyHash("unknown") }},
+		}
+		for _, test := range tests {
+			user, err := test.lookup()
+			if err != nil {
+				t.Errorf("%v %v: unexpected error %v", name, test.name, err)
+				continue
+			}
+			if test.id == "" && user != nil {
+				t.Errorf("%v %v: expected no user, got %v", name, test.name, user.ID)
+			}
+			if test.id != "" && (user == nil || user.ID != test.id) {
+				t.Errorf("%v %v: expected %v, got %v", name, test.name, test.id, user)
+			}
+		}
+
+		if err := store.AddUser(&User{ID: "other", Email: "alice@example.com"}); err == nil {
+			t.Errorf("%v: expected an error adding a user with a taken email", name)
+		}
+		if err := store.DeleteUser("nobody@example.com"); err == nil {
+			t.Errorf("%v: expected an error deleting an unknown user", name)
+		}
+	}
+}
+
+func TestStoreUpdateUser(t *testing.T) {
+	stores, cleanup := testStores(t)
+	defer cleanup()
+
+	for name, store := range stores {
+		for _, email := range []string{"alice@example.com", "bob@example.com"} {
+			user, err := NewUser(email, "", "secret")
+			if err != nil {
+				t.Fatalf("%v: error creating user: %v", name, err)
+			}
+			if err := store.AddUser(user); err != nil {
+				t.Fatalf("%v: error adding user: %v", name, err)
+			}
+		}
+		alice, _ := store.LookupByEmail("alice@example.com")
+
+		tests := []struct {
+			name  string
+			email string
+			fn    func(user *User) error
+			err   bool
+		}{
+			{name: "rename", email: "alice@example.com", fn: func(user *User) error {
+				user.Name = "Alice"
+				return nil
+			}},
+			{name: "taken email", email: "alice@example.com", fn: func(user *User) error {
+				user.Email = "bob@example.com"
+				return nil
+			}, err: true},
+			{name: "unknown user", email: "nobody@example.com", fn: func(user *User) error {
+				return nil
+			}, err: true},
+			{name: "create key", email: "alice@example.com", fn: func(user *User) error {
+				_, _, err := user.CreateKey("importer", KeyScope{}, 0)
+				return err
+			}},
+			{name: "change email", email: "alice@example.com", fn: func(user *User) error {
+				user.Email = "alice@example.org"
+				return nil
+			}},
+		}
+		for _, test := range tests {
+			version, _ := store.Version()
+			err := store.UpdateUser(test.email, test.fn)
+			if test.err != (err != nil) {
+				t.Errorf("%v %v: expected an error %v, got %v", name, test.name, test.err, err)
+			}
+			current, _ := store.Version()
+			if changed := !bytes.Equal(version, current); changed == test.err {
+				t.Errorf("%v %v: expected the version to change only for successful writes", name, test.name)
+			}
+		}
+
+		user, err := store.Get(alice.ID)
+		if err != nil || user == nil {
+			t.Fatalf("%v: expected to find the user, got %v, %v", name, user, err)
+		}
+		if user.Name != "Alice" || user.Email != "alice@example.org" || len(user.Keys) != 1 {
+			t.Errorf("%v: expected the changes to be stored, got %v", name, user)
+		}
+		if old, _ := store.LookupByEmail("alice@example.com"); old != nil {
+			t.Errorf("%v: expected the old email to be free", name)
+		}
+		if byKey, _ := store.LookupByKeyHash(user.Keys[0].Hash); byKey == nil || byKey.ID != alice.ID {
+			t.Errorf("%v: expected to find the user by the new key, got %v", name, byKey)
+		}
+
+		if err := store.DeleteUser("alice@example.org"); err != nil {
+			t.Fatalf("%v: error deleting user: %v", name, err)
+		}
+		if byKey, _ := store.LookupByKeyHash(user.Keys[0].Hash); byKey != nil {
+			t.Errorf("%v: expected the keys of a deleted user to be gone", name)
+		}
+	}
+}
+
+func TestStoreUpdate(t *testing.T) {
+	stores, cleanup := testStores(t)
+	defer cleanup()
+
+	for name, store := range stores {
+		user, _ := NewUser("alice@example.com", "Alice", "secret")
+		store.AddUser(user)
+		err := store.Update(func(db *UserDB) error {
+			if err := db.AddGroup("marketing", nil, nil); err != nil {
+				return err
+			}
+			return db.Grant(db.LookupByEmail("alice@example.com"), "marketing", true)
+		})
+		if err != nil {
+			t.Fatalf("%v: error updating: %v", name, err)
+		}
+
+		roles, err := store.LoadRoles()
+		if err != nil {
+			t.Fatalf("%v: error loading roles: %v", name, err)
+		}
+		if roles.Group("marketing") == nil || len(roles.Users) != 0 {
+			t.Errorf("%v: expected the groups without users, got %v", name, roles)
+		}
+		if found, _ := store.LookupByEmail("alice@example.com"); found == nil || !contains(found.Groups, "marketing") {
+			t.Errorf("%v: expected the user to be in the group, got %v", name, found)
+		}
+
+		version, _ := store.Version()
+		err = store.Update(func(db *UserDB) error {
+			db.Users = nil
+			return db.DeleteGroup("unknown")
+		})
+		if err == nil {
+			t.Errorf("%v: expected the error of the update", name)
+		}
+		if current, _ := store.Version(); !bytes.Equal(version, current) {
+			t.Errorf("%v: expected a failed update not to be saved", name)
+		}
+		if found, _ := store.LookupByEmail("alice@example.com"); found == nil {
+			t.Errorf("%v: expected the user to stay after a failed update", name)
+		}
+	}
+}
+
+func TestStoreLogins(t *testing.T) {
+	dir, cleanup := tempDBPath(t, "")
+	defer cleanup()
+
+	tests := []struct {
+		name   string
+		store  Store
+		reopen func() Store
+	}{
+		{name: "memory", store: NewMemoryStore(&UserDB{})},
+		{name: "yaml", store: NewStore(filepath.Join(dir, "users.yml")), reopen: func() Store { return NewStore(filepath.Join(dir, "users.yml")) }},
+		{name: "sqlite", store: NewStore(filepath.Join(dir, "users.db")), reopen: func() Store { return NewStore(filepath.Join(dir, "users.db")) }},
+	}
+
+	for _, test := range tests {
+		user, _ := NewUser("alice@example.com", "Alice", "secret")
+		if err := test.store.AddUser(user); err != nil {
+			t.Fatalf("%v: error adding user: %v", test.name, err)
+		}
+		if last, err := test.store.LastLogin(user.ID); err != nil || last != nil {
+			t.Errorf("%v: expected no last login, got %v, %v", test.name, last, err)
+		}
+
+		version, _ := test.store.Version()
+		before := time.Now().Add(-time.Second)
+		if err := test.store.RecordLogin(user.ID); err != nil {
+			t.Fatalf("%v: error recording login: %v", test.name, err)
+		}
+		if current, _ := test.store.Version(); !bytes.Equal(version, current) {
+			t.Errorf("%v: expected a login not to change the user db", test.name)
+		}
+
+		stores := []Store{test.store}
+		if test.reopen != nil {
+			stores = append(stores, test.reopen())
+		}
+		for _, store := range stores {
+			last, err := store.LastLogin(user.ID)
+			if err != nil || last == nil || last.Before(before) {
+				t.Errorf("%v: expected the login to be recorded, got %v, %v", test.name, last, err)
+			}
+		}
+		if last, _ := test.store.LastLogin("other"); last != nil {
+			t.Errorf("%v: expected no last login of another user, got %v", test.name, last)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package userdb
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	// totpSkew is the number of periods before and after now that are accepted
+	totpSkew = 1
+)
+
+// TOTPEnabled checks if the user has enrolled in two factor authentication
+func (u *User) TOTPEnabled() bool {
+	return u.TOTPSecret != ""
+}
+
+// EnableTOTP generates a new TOTP secret for the user and returns the otpauth
+// URI to add to an authenticator app
+func (u *User) EnableTOTP(issuer string) (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	u.TOTPSecret = base32.StdEncoding.EncodeToString(secret)
+
+	query := url.Values{}
+	query.Set("secret", strings.TrimRight(u.TOTPSecret, "="))
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprintf("%v", totpDigits))
+	query.Set("period", fmt.Sprintf("%v", totpPeriod))
+	uri := &url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + u.Email,
+		RawQuery: query.Encode(),
+	}
+	return uri.String(), nil
+}
+
+// DisableTOTP removes the TOTP secret of the user
+func (u *User) DisableTOTP() {
+	u.TOTPSecret = ""
+}
+
+// VerifyTOTP checks a one time password against the user's TOTP secret. Only
+// codes for time steps after lastStep are accepted, so a code can't be used
+// again once its step was recorded. Returns the time step of the code.
+func (u *User) VerifyTOTP(code string, lastStep int64) (int64, bool) {
+	return u.verifyTOTPAt(code, lastStep, time.Now())
+}
+
+func (u *User) verifyTOTPAt(code string, lastStep int64, now time.Time) (int64, bool) {
+	if !u.TOTPEnabled() || len(code) != totpDigits {
+		return 0, false
+	}
+
+	secret, err := base32.StdEncoding.DecodeString(u.TOTPSecret)
+	if err != nil {
+		return 0, false
+	}
+
+	counter := now.Unix() / totpPeriod
+	for i := int64(-totpSkew); i <= totpSkew; i++ {
+		if counter+i <= lastStep {
+			continue
+		}
+		expected := totpCode(secret, counter+i)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return counter + i, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPGuard verifies one time passwords and remembers the last accepted time
+// step of each user so a code can't be replayed while it's still valid
+type TOTPGuard struct {
+	mutex sync.Mutex
+	steps map[string]int64
+}
+
+// NewTOTPGuard creates a guard without any recorded steps
+func NewTOTPGuard() *TOTPGuard {
+	return &TOTPGuard{steps: map[string]int64{}}
+}
+
+// Verify checks a one time password of a user and records its time step
+func (g *TOTPGuard) Verify(user *User, code string) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	step, ok := user.VerifyTOTP(code, g.steps[user.ID])
+	if ok {
+		g.steps[user.ID] = step
+	}
+	return ok
+}
+
+// totpModulus is 10^totpDigits
+func totpModulus() uint32 {
+	modulus := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		modulus *= 10
+	}
+	return modulus
+}
+
+// totpCode computes the code for a counter as specified in RFC 4226
+func totpCode(secret []byte, counter int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0xf
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%totpModulus())
+}
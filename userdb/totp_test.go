@@ -0,0 +1,114 @@
+package userdb
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// The SHA1 test vectors from RFC 6238, truncated to 6 digits
+	secret := []byte("12345678901234567890")
+	tests := []struct {
+		time     int64
+		expected string
+	}{
+		{time: 59, expected: "287082"},
+		{time: 1111111109, expected: "081804"},
+		{time: 1111111111, expected: "050471"},
+		{time: 1234567890, expected: "005924"},
+		{time: 2000000000, expected: "279037"},
+		{time: 20000000000, expected: "353130"},
+	}
+
+	for _, test := range tests {
+		if code := totpCode(secret, test.time/totpPeriod); code != test.expected {
+			t.Errorf("totpCode at %v: expected %v, got %v", test.time, test.expected, code)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	user := &User{TOTPSecret: base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))}
+	now := time.Unix(1111111109, 0)
+	step := now.Unix() / totpPeriod
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		ok       bool
+		step     int64
+	}{
+		{name: "current code", code: "081804", ok: true, step: step},
+		{name: "previous code", code: totpCode([]byte("12345678901234567890"), step-1), ok: true, step: step - 1},
+		{name: "next code", code: totpCode([]byte("12345678901234567890"), step+1), ok: true, step: step + 1},
+		{name: "old code", code: totpCode([]byte("12345678901234567890"), step-2), ok: false},
+		{name: "wrong code", code: "000000", ok: false},
+		{name: "short code", code: "81804", ok: false},
+		{name: "replayed code", code: "081804", lastStep: step, ok: false},
+		{name: "code before the last step", code: totpCode([]byte("12345678901234567890"), step-1), lastStep: step, ok: false},
+		{name: "code after the last step", code: totpCode([]byte("12345678901234567890"), step+1), lastStep: step, ok: true, step: step + 1},
+	}
+
+	for _, test := range tests {
+		step, ok := user.verifyTOTPAt(test.code, test.lastStep, now)
+		if ok != test.ok || step != test.step {
+			t.Errorf("%v: expected %v, %v, got %v, %v", test.name, test.step, test.ok, step, ok)
+		}
+	}
+
+	if _, ok := (&User{}).verifyTOTPAt("081804", 0, now); ok {
+		t.Errorf("Expected codes to be rejected for users without 2fa")
+	}
+}
+
+func TestTOTPGuard(t *testing.T) {
+	user := &User{ID: "user-1", TOTPSecret: base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))}
+	other := &User{ID: "user-2", TOTPSecret: user.TOTPSecret}
+	code := totpCode([]byte("12345678901234567890"), time.Now().Unix()/totpPeriod)
+
+	guard := NewTOTPGuard()
+	if !guard.Verify(user, code) {
+		t.Fatalf("Expected the current code to be accepted")
+	}
+	if guard.Verify(user, code) {
+		t.Errorf("Expected the code to be rejected the second time")
+	}
+	if !guard.Verify(other, code) {
+		t.Errorf("Expected the steps to be tracked per user")
+	}
+}
+
+func TestEnableTOTP(t *testing.T) {
+	user := &User{Email: "test@example.com"}
+	uri, err := user.EnableTOTP("netlify-git-api")
+	if err != nil {
+		t.Fatalf("Error enabling 2fa: %v", err)
+	}
+	if !user.TOTPEnabled() {
+		t.Fatalf("Expected 2fa to be enabled")
+	}
+
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("Error parsing %v: %v", uri, err)
+	}
+	query := parsed.Query()
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" || parsed.Path != "/netlify-git-api:test@example.com" {
+		t.Errorf("Unexpected otpauth uri %v", uri)
+	}
+	if query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Errorf("Unexpected parameters in %v", uri)
+	}
+	if query.Get("secret") != strings.TrimRight(user.TOTPSecret, "=") {
+		t.Errorf("Expected the secret %v in %v", user.TOTPSecret, uri)
+	}
+
+	user.DisableTOTP()
+	if user.TOTPEnabled() {
+		t.Errorf("Expected 2fa to be disabled")
+	}
+}